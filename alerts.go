@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAlertCooldown = 5 * time.Minute
+	defaultAlertContext  = 5
+	alertContextTimeout  = 5 * time.Second // Maximum wait for the lines after a match
+)
+
+// AlertCallback is called when an output line of a service matches one of its alerts
+type AlertCallback func(serviceName string, alert LogAlert)
+
+// LogAlert is an output line that matched an alert pattern, with the surrounding lines of its stream
+type LogAlert struct {
+	Pattern string
+	Line    LogLine
+	Before  []LogLine
+	After   []LogLine
+}
+
+// alertRule is a compiled alert
+type alertRule struct {
+	pattern   *regexp.Regexp
+	stream    string // Empty = both streams
+	context   int
+	cooldown  time.Duration
+	lastFired time.Time
+}
+
+// pendingAlert is a match waiting for the lines after it
+type pendingAlert struct {
+	alert LogAlert
+	want  int // Number of lines after the match to collect
+	fired bool
+}
+
+// alertMatcher checks output lines against a service's alerts. An alert is reported once the
+// context lines after the match have arrived, or after alertContextTimeout.
+type alertMatcher struct {
+	mu         sync.Mutex
+	rules      []*alertRule
+	recent     map[string][]LogLine // Last lines of each stream (context before a match)
+	maxContext int
+	pending    []*pendingAlert
+	callback   func(LogAlert)
+}
+
+// newAlertMatcher compiles the alerts of a service. Invalid patterns are reported and skipped;
+// returns nil if there are no valid alerts.
+func newAlertMatcher(serviceName string, configs []AlertConfig) *alertMatcher {
+	m := &alertMatcher{recent: make(map[string][]LogLine)}
+	for _, cfg := range configs {
+		pattern, err := regexp.Compile(cfg.Pattern)
+		if err != nil || cfg.Pattern == "" {
+			fmt.Fprintf(os.Stderr, "[%s] Invalid alert pattern %q: %v\n", serviceName, cfg.Pattern, err)
+			continue
+		}
+		rule := &alertRule{
+			pattern:  pattern,
+			stream:   cfg.Stream,
+			context:  cfg.ContextLines(),
+			cooldown: cfg.CooldownDuration(),
+		}
+		m.rules = append(m.rules, rule)
+		m.maxContext = max(m.maxContext, rule.context)
+	}
+	if len(m.rules) == 0 {
+		return nil
+	}
+	return m
+}
+
+// SetCallback sets the function called (in its own goroutine) for every alert
+func (m *alertMatcher) SetCallback(callback func(LogAlert)) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callback = callback
+}
+
+// Check matches a new output line against the alerts
+func (m *alertMatcher) Check(line LogLine) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Complete the context of earlier matches
+	for _, p := range m.pending {
+		if p.alert.Line.Stream == line.Stream {
+			p.alert.After = append(p.alert.After, line)
+			if len(p.alert.After) >= p.want {
+				m.fire(p)
+			}
+		}
+	}
+
+	for _, rule := range m.rules {
+		if rule.stream != "" && rule.stream != line.Stream {
+			continue
+		}
+		if !rule.lastFired.IsZero() && line.Time.Sub(rule.lastFired) < rule.cooldown {
+			continue
+		}
+		if !rule.pattern.MatchString(line.Text) {
+			continue
+		}
+		rule.lastFired = line.Time
+
+		recent := m.recent[line.Stream]
+		before := append([]LogLine(nil), recent[max(0, len(recent)-rule.context):]...)
+		p := &pendingAlert{
+			alert: LogAlert{Pattern: rule.pattern.String(), Line: line, Before: before},
+			want:  rule.context,
+		}
+		if p.want == 0 {
+			m.fire(p)
+			continue
+		}
+		m.pending = append(m.pending, p)
+		time.AfterFunc(alertContextTimeout, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.fire(p)
+		})
+	}
+
+	// Remember the line as context for later matches
+	recent := append(m.recent[line.Stream], line)
+	if len(recent) > m.maxContext {
+		recent = recent[len(recent)-m.maxContext:]
+	}
+	m.recent[line.Stream] = recent
+}
+
+// fire reports an alert once. Caller must hold the lock
+func (m *alertMatcher) fire(p *pendingAlert) {
+	if p.fired {
+		return
+	}
+	p.fired = true
+
+	for i, other := range m.pending {
+		if other == p {
+			m.pending = append(m.pending[:i], m.pending[i+1:]...)
+			break
+		}
+	}
+
+	// The callback runs on its own so it can take other locks while output keeps flowing
+	if m.callback != nil {
+		go m.callback(p.alert)
+	}
+}
+
+// logLineTexts returns the text of each line
+func logLineTexts(lines []LogLine) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return texts
+}
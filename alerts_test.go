@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertMatcher_ContextAndCooldown(t *testing.T) {
+	context := 1
+	m := newAlertMatcher("svc", []AlertConfig{{Pattern: "FATAL|panic:", Stream: "stderr", Cooldown: "10m", Context: &context}})
+	alerts := make(chan LogAlert, 10)
+	m.SetCallback(func(alert LogAlert) { alerts <- alert })
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lines := []LogLine{
+		{Time: base, Stream: "stderr", Text: "connecting"},
+		{Time: base, Stream: "stdout", Text: "FATAL on stdout is ignored"},
+		{Time: base, Stream: "stderr", Text: "FATAL: lost connection"},
+		{Time: base, Stream: "stdout", Text: "still serving"},
+		{Time: base, Stream: "stderr", Text: "degraded mode"},
+		{Time: base.Add(time.Minute), Stream: "stderr", Text: "panic: again (cooldown)"},
+		{Time: base.Add(11 * time.Minute), Stream: "stderr", Text: "panic: after cooldown"},
+	}
+	for _, line := range lines {
+		m.Check(line)
+	}
+
+	select {
+	case alert := <-alerts:
+		if alert.Line.Text != "FATAL: lost connection" {
+			t.Errorf("Unexpected matching line %q", alert.Line.Text)
+		}
+		if len(alert.Before) != 1 || alert.Before[0].Text != "connecting" {
+			t.Errorf("Unexpected context before: %+v", alert.Before)
+		}
+		if len(alert.After) != 1 || alert.After[0].Text != "degraded mode" {
+			t.Errorf("Unexpected context after: %+v", alert.After)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No alert fired")
+	}
+
+	// The match after the cooldown waits for its context (or the timeout)
+	select {
+	case alert := <-alerts:
+		t.Errorf("Unexpected alert before its context arrived: %q", alert.Line.Text)
+	case <-time.After(100 * time.Millisecond):
+	}
+	m.Check(LogLine{Time: base.Add(11 * time.Minute), Stream: "stderr", Text: "exiting"})
+	select {
+	case alert := <-alerts:
+		if alert.Line.Text != "panic: after cooldown" {
+			t.Errorf("Unexpected matching line %q", alert.Line.Text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No alert fired after the cooldown")
+	}
+}
+
+func TestNewAlertMatcher_InvalidPattern(t *testing.T) {
+	if m := newAlertMatcher("svc", []AlertConfig{{Pattern: "("}}); m != nil {
+		t.Errorf("Expected no matcher for an invalid pattern")
+	}
+	var m *alertMatcher
+	m.Check(LogLine{Text: "no alerts configured"}) // Must not panic
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequence handling (ansi)
+const (
+	ANSIKeep   = "keep"   // Escape sequences are logged and delivered as-is (default)
+	ANSIStrip  = "strip"  // Removed from the log files and sinks; live lines keep them
+	ANSIRender = "render" // Like strip, and live lines also carry the colors as HTML
+)
+
+const ansiEscape = 0x1b
+
+// applyANSIMode returns the text of an output line to write to the log files and sinks and,
+// with ansi: render, its colors as HTML for live lines (which keep the original text)
+func applyANSIMode(mode, text string) (string, string) {
+	switch mode {
+	case ANSIStrip:
+		return stripANSI(text), ""
+	case ANSIRender:
+		return stripANSI(text), renderANSI(text)
+	}
+	return text, ""
+}
+
+// ansiSequenceEnd returns the end of the escape sequence starting at s[i] (an ESC byte),
+// or -1 if the sequence is incomplete
+func ansiSequenceEnd(s string, i int) int {
+	j := i + 1
+	if j >= len(s) {
+		return -1
+	}
+
+	switch s[j] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte
+		for j++; j < len(s); j++ {
+			c := s[j]
+			if c >= 0x40 && c <= 0x7e {
+				return j + 1
+			}
+			if c < 0x20 || c > 0x3f {
+				return j // Malformed, the sequence ends before this byte
+			}
+		}
+		return -1
+
+	case ']', 'P', '_', '^':
+		// OSC, DCS, APC, PM: terminated by BEL or ST (ESC \)
+		for j++; j < len(s); j++ {
+			if s[j] == 0x07 {
+				return j + 1
+			}
+			if s[j] == ansiEscape && j+1 < len(s) && s[j+1] == '\\' {
+				return j + 2
+			}
+		}
+		return -1
+	}
+
+	// Other sequences: optional intermediate bytes and a final byte (e.g. ESC ( B)
+	for j < len(s) && s[j] >= 0x20 && s[j] <= 0x2f {
+		j++
+	}
+	if j >= len(s) {
+		return -1
+	}
+	return j + 1
+}
+
+// stripANSI removes all escape sequences from text (an incomplete sequence at the end is dropped)
+func stripANSI(text string) string {
+	if strings.IndexByte(text, ansiEscape) < 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] != ansiEscape {
+			next := strings.IndexByte(text[i:], ansiEscape)
+			if next < 0 {
+				sb.WriteString(text[i:])
+				break
+			}
+			sb.WriteString(text[i : i+next])
+			i += next
+			continue
+		}
+
+		end := ansiSequenceEnd(text, i)
+		if end < 0 {
+			break
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// safeCut removes a UTF-8 character or escape sequence that was cut off at the end of text
+func safeCut(text string) string {
+	for i := len(text) - 1; i >= 0 && i >= len(text)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(text[i]) {
+			if !utf8.FullRuneInString(text[i:]) {
+				text = text[:i]
+			}
+			break
+		}
+	}
+
+	if i := strings.LastIndexByte(text, ansiEscape); i >= 0 && ansiSequenceEnd(text, i) < 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+// truncateLogText shortens text to about maxLen bytes without cutting a UTF-8 character or escape
+// sequence, and appends a marker with the number of removed bytes
+func truncateLogText(text string, maxLen int) string {
+	if len(text) <= maxLen {
+		return text
+	}
+	cut := safeCut(text[:max(maxLen, 0)])
+	return fmt.Sprintf("%s [truncated %d bytes]", cut, len(text)-len(cut))
+}
+
+// ansiStyle is the SGR state while rendering
+type ansiStyle struct {
+	fg, bg                                string // Palette index (0-15) or #rrggbb, empty = default
+	bold, dim, italic, underline, inverse bool
+}
+
+// span returns the opening tag for the style, or "" for the default style
+func (st ansiStyle) span() string {
+	fg, bg := st.fg, st.bg
+	if st.inverse {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "0"
+		}
+		if bg == "" {
+			bg = "7"
+		}
+	}
+
+	var classes, styles []string
+	for _, flag := range []struct {
+		on   bool
+		name string
+	}{{st.bold, "bold"}, {st.dim, "dim"}, {st.italic, "italic"}, {st.underline, "underline"}} {
+		if flag.on {
+			classes = append(classes, "ansi-"+flag.name)
+		}
+	}
+	if strings.HasPrefix(fg, "#") {
+		styles = append(styles, "color:"+fg)
+	} else if fg != "" {
+		classes = append(classes, "ansi-fg-"+fg)
+	}
+	if strings.HasPrefix(bg, "#") {
+		styles = append(styles, "background-color:"+bg)
+	} else if bg != "" {
+		classes = append(classes, "ansi-bg-"+bg)
+	}
+
+	if len(classes) == 0 && len(styles) == 0 {
+		return ""
+	}
+	tag := "<span"
+	if len(classes) > 0 {
+		tag += ` class="` + strings.Join(classes, " ") + `"`
+	}
+	if len(styles) > 0 {
+		tag += ` style="` + strings.Join(styles, ";") + `"`
+	}
+	return tag + ">"
+}
+
+// apply updates the style with the parameters of an SGR sequence (ESC [ params m)
+func (st *ansiStyle) apply(params string) {
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil && codes[i] != "" {
+			continue
+		}
+		switch {
+		case code == 0:
+			*st = ansiStyle{}
+		case code == 1:
+			st.bold = true
+		case code == 2:
+			st.dim = true
+		case code == 3:
+			st.italic = true
+		case code == 4:
+			st.underline = true
+		case code == 7:
+			st.inverse = true
+		case code == 22:
+			st.bold, st.dim = false, false
+		case code == 23:
+			st.italic = false
+		case code == 24:
+			st.underline = false
+		case code == 27:
+			st.inverse = false
+		case code >= 30 && code <= 37:
+			st.fg = strconv.Itoa(code - 30)
+		case code >= 90 && code <= 97:
+			st.fg = strconv.Itoa(code - 90 + 8)
+		case code == 39:
+			st.fg = ""
+		case code >= 40 && code <= 47:
+			st.bg = strconv.Itoa(code - 40)
+		case code >= 100 && code <= 107:
+			st.bg = strconv.Itoa(code - 100 + 8)
+		case code == 49:
+			st.bg = ""
+		case code == 38 || code == 48:
+			color, used := extendedColor(codes[i+1:])
+			i += used
+			if code == 38 {
+				st.fg = color
+			} else {
+				st.bg = color
+			}
+		}
+	}
+}
+
+// extendedColor parses the arguments of a 256-color (5;n) or truecolor (2;r;g;b) SGR code.
+// Returns the color and the number of arguments used.
+func extendedColor(args []string) (string, int) {
+	num := func(i int) int {
+		if i >= len(args) {
+			return 0
+		}
+		n, _ := strconv.Atoi(args[i])
+		return min(max(n, 0), 255)
+	}
+	if len(args) == 0 {
+		return "", 0
+	}
+
+	switch args[0] {
+	case "5":
+		n := num(1)
+		switch {
+		case n < 16:
+			return strconv.Itoa(n), 2
+		case n < 232:
+			// 6x6x6 color cube
+			levels := [6]int{0, 95, 135, 175, 215, 255}
+			n -= 16
+			return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6]), 2
+		default:
+			gray := 8 + (n-232)*10
+			return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray), 2
+		}
+	case "2":
+		return fmt.Sprintf("#%02x%02x%02x", num(1), num(2), num(3)), 4
+	}
+	return "", 1
+}
+
+// renderANSI converts the SGR color and style sequences of text to HTML spans (the text is
+// escaped, other sequences are removed). Returns "" if the text contains no escape sequences.
+func renderANSI(text string) string {
+	if strings.IndexByte(text, ansiEscape) < 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	var style ansiStyle
+	open := false
+	for i := 0; i < len(text); {
+		if text[i] != ansiEscape {
+			end := strings.IndexByte(text[i:], ansiEscape)
+			if end < 0 {
+				end = len(text)
+			} else {
+				end += i
+			}
+			sb.WriteString(html.EscapeString(text[i:end]))
+			i = end
+			continue
+		}
+
+		end := ansiSequenceEnd(text, i)
+		if end < 0 {
+			break
+		}
+		if seq := text[i:end]; strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m") {
+			style.apply(seq[2 : len(seq)-1])
+			if open {
+				sb.WriteString("</span>")
+			}
+			tag := style.span()
+			sb.WriteString(tag)
+			open = tag != ""
+		}
+		i = end
+	}
+	if open {
+		sb.WriteString("</span>")
+	}
+	return sb.String()
+}
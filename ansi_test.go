@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	tests := map[string]string{
+		"plain":                                "plain",
+		"\x1b[1;31merror\x1b[0m: failed":       "error: failed",
+		"\x1b]8;;http://x\x07link\x1b]8;;\x07": "link",
+		"progress\x1b[2K\x1b[1Gdone":           "progressdone",
+		"charset \x1b(Bok":                     "charset ok",
+		"cut off \x1b[3":                       "cut off ",
+	}
+	for input, want := range tests {
+		if got := stripANSI(input); got != want {
+			t.Errorf("stripANSI(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRenderANSI(t *testing.T) {
+	tests := map[string]string{
+		"no colors":                        "",
+		"\x1b[31mred\x1b[0m <b>":           `<span class="ansi-fg-1">red</span> &lt;b&gt;`,
+		"\x1b[1;92mok\x1b[22m plain green": `<span class="ansi-bold ansi-fg-10">ok</span><span class="ansi-fg-10"> plain green</span>`,
+		"\x1b[38;5;208morange\x1b[m":       `<span style="color:#ff8700">orange</span>`,
+		"\x1b[48;2;1;2;3mbg\x1b[K done":    `<span style="background-color:#010203">bg done</span>`,
+	}
+	for input, want := range tests {
+		if got := renderANSI(input); got != want {
+			t.Errorf("renderANSI(%q) =\n %s\nwant\n %s", input, got, want)
+		}
+	}
+}
+
+func TestSafeCut(t *testing.T) {
+	tests := map[string]string{
+		"complete":            "complete",
+		"rune \xe2\x82":       "rune ",
+		"seq \x1b[38;5":       "seq ",
+		"done \x1b[0m":        "done \x1b[0m",
+		"osc \x1b]8;;http://": "osc ",
+	}
+	for input, want := range tests {
+		if got := safeCut(input); got != want {
+			t.Errorf("safeCut(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestApplyANSIMode(t *testing.T) {
+	raw := "\x1b[33mwarn\x1b[0m"
+	if text, html := applyANSIMode("", raw); text != raw || html != "" {
+		t.Errorf("keep: got %q, %q", text, html)
+	}
+	if text, html := applyANSIMode(ANSIStrip, raw); text != "warn" || html != "" {
+		t.Errorf("strip: got %q, %q", text, html)
+	}
+	if text, html := applyANSIMode(ANSIRender, raw); text != "warn" || html != `<span class="ansi-fg-3">warn</span>` {
+		t.Errorf("render: got %q, %q", text, html)
+	}
+}
+
+func TestService_ANSIStripOnlyInLogFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web", ANSI: ANSIRender}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	raw := "\x1b[31mfailed\x1b[0m"
+	svc.readLogs(strings.NewReader(raw+"\n"), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.closeLogFiles()
+
+	data, _ := os.ReadFile(svc.stdoutLogPath())
+	if string(data) != "failed\n" {
+		t.Errorf("Expected the log file without escape sequences, got %q", data)
+	}
+	lines := svc.stdoutBuf.Lines()
+	if len(lines) != 1 || lines[0].Text != raw || lines[0].HTML != `<span class="ansi-fg-1">failed</span>` {
+		t.Errorf("Expected the live line with the original text and HTML, got %+v", lines)
+	}
+}
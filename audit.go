@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Audited actions
+const (
+	AuditStart       = "start"
+	AuditStop        = "stop"
+	AuditRestart     = "restart"
+	AuditRunNow      = "run-now"
+	AuditEnable      = "enable"
+	AuditDisable     = "disable"
+	AuditCreate      = "create"
+	AuditUpdate      = "update"
+	AuditDelete      = "delete"
+	AuditClearLogs   = "clear-logs"
+	AuditAutoRestart = "auto-restart" // Automatic restart after the process exited
+	AuditGiveUp      = "give-up"      // Automatic restarts stopped after too many consecutive failures
+)
+
+// Actors that are not API users
+const (
+	ActorWatcher = "watcher" // External edit of services.yaml detected by the config watcher
+	ActorAuto    = "auto"    // The manager itself (automatic restarts)
+)
+
+// AuditEntry records a state-changing action
+type AuditEntry struct {
+	Time    time.Time      `json:"time"`
+	Action  string         `json:"action"`
+	Service string         `json:"service,omitempty"`
+	Actor   string         `json:"actor"`        // Authenticated user, "watcher" or "auto"
+	IP      string         `json:"ip,omitempty"` // Source IP of API requests
+	Details string         `json:"details,omitempty"`
+	Changes []ConfigChange `json:"changes,omitempty"` // Configuration before/after (create, update, delete, enable, disable)
+}
+
+// ConfigChange is a changed top-level setting of a service (as in services.yaml)
+type ConfigChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries (empty fields match everything)
+type AuditFilter struct {
+	Service string
+	Action  string
+	Actor   string
+	Since   time.Time
+	Until   time.Time
+	Allowed func(service string) bool // Services the reader can see (nil = all)
+}
+
+// matches reports whether an entry passes the filter
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	return (f.Service == "" || entry.Service == f.Service) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Actor == "" || entry.Actor == f.Actor) &&
+		(f.Since.IsZero() || !entry.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || !entry.Time.After(f.Until)) &&
+		(f.Allowed == nil || entry.Service == "" || f.Allowed(entry.Service))
+}
+
+// Audit log retention defaults
+const (
+	defaultAuditMaxSize = 10 * 1024 * 1024 // Rotate the audit log once it would exceed this size
+	defaultAuditKeep    = 5                // Number of rotated audit log files to keep
+)
+
+// AuditLog is a durable, append-only log of audit entries stored as JSON lines. The file is
+// rotated to path.1 (path.2, ... for older ones) once it would exceed maxSize, keeping the
+// newest keep rotated files.
+type AuditLog struct {
+	path    string
+	maxSize int64
+	keep    int
+	mu      sync.Mutex
+}
+
+// NewAuditLog creates an audit log backed by the given file
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path, maxSize: defaultAuditMaxSize, keep: defaultAuditKeep}
+}
+
+// files returns the paths of the audit log files, newest first
+func (a *AuditLog) files() []string {
+	paths := []string{a.path}
+	for i := 1; i <= a.keep; i++ {
+		paths = append(paths, fmt.Sprintf("%s.%d", a.path, i))
+	}
+	return paths
+}
+
+// Record appends an entry (a nil log records nothing). Failures are logged to stderr, so
+// auditing never makes an action fail.
+func (a *AuditLog) Record(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if err := a.append(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record audit entry (%s %s): %v\n", entry.Action, entry.Service, err)
+	}
+}
+
+func (a *AuditLog) append(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	if info, err := os.Stat(a.path); err == nil && info.Size() > 0 && info.Size()+int64(len(data)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// rotate renames the audit log to path.1, shifting the older files and deleting the oldest.
+// Caller must hold the lock
+func (a *AuditLog) rotate() error {
+	paths := a.files()
+	if err := os.Remove(paths[len(paths)-1]); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := len(paths) - 2; i >= 0; i-- {
+		if err := os.Rename(paths[i], paths[i+1]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns up to limit matching entries starting at offset, newest first, and whether
+// more matching entries follow. The files are read backwards, so only the entries up to the
+// requested page are read.
+func (a *AuditLog) Query(filter AuditFilter, offset, limit int) ([]AuditEntry, bool, error) {
+	if a == nil {
+		return []AuditEntry{}, false, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	result := []AuditEntry{}
+	skipped, more := 0, false
+	for _, path := range a.files() {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, false, err
+		}
+		err = scanLinesBackward(file, func(line []byte) bool {
+			var entry AuditEntry
+			if err := json.Unmarshal(line, &entry); err != nil || !filter.matches(entry) {
+				return true
+			}
+			if skipped < offset {
+				skipped++
+				return true
+			}
+			if len(result) == limit {
+				more = true
+				return false
+			}
+			result = append(result, entry)
+			return true
+		})
+		file.Close()
+		if err != nil {
+			return nil, false, err
+		}
+		if more {
+			break
+		}
+	}
+	return result, more, nil
+}
+
+// scanLinesBackward calls fn for every non-empty line of a file, last line first, until fn
+// returns false
+func scanLinesBackward(file *os.File, fn func(line []byte) bool) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	const chunkSize = 64 * 1024
+	chunk := make([]byte, chunkSize)
+	var rest []byte // Start of the file up to the lines already passed to fn (from the chunks read so far)
+	for pos := info.Size(); pos > 0; {
+		n := min(pos, chunkSize)
+		pos -= n
+		if _, err := file.ReadAt(chunk[:n], pos); err != nil {
+			return err
+		}
+		rest = append(chunk[:n:n], rest...)
+
+		// Every line after a newline is complete
+		for {
+			idx := bytes.LastIndexByte(rest, '\n')
+			if idx < 0 {
+				break
+			}
+			if line := rest[idx+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+			rest = rest[:idx]
+		}
+		rest = bytes.Clone(rest) // Don't keep the chunk, it is reused
+	}
+	if len(rest) > 0 {
+		fn(rest)
+	}
+	return nil
+}
+
+// configDiff returns the settings that differ between two service configurations (nil = the
+// service doesn't exist). Values are compared as written to services.yaml and reported with
+// secrets redacted (see redactYAML), so a changed secret shows up as a change of its setting.
+func configDiff(before, after *ServiceConfig) []ConfigChange {
+	beforeFields, afterFields := configFields(before, false), configFields(after, false)
+
+	var fields []string
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []ConfigChange
+	redactedBefore, redactedAfter := configFields(before, true), configFields(after, true)
+	for _, field := range fields {
+		if !reflect.DeepEqual(beforeFields[field], afterFields[field]) {
+			changes = append(changes, ConfigChange{Field: field, Before: redactedBefore[field], After: redactedAfter[field]})
+		}
+	}
+	return changes
+}
+
+// configFields returns the settings of a service as they appear in services.yaml, optionally
+// with secrets redacted
+func configFields(cfg *ServiceConfig, redact bool) map[string]any {
+	fields := map[string]any{}
+	if cfg == nil {
+		return fields
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fields
+	}
+	if redact {
+		if data, err = redactYAML(data); err != nil {
+			return fields
+		}
+	}
+	yaml.Unmarshal(data, &fields)
+	return fields
+}
+
+// configChangesAudit returns the audit entries for an external edit of the services: one per
+// created, updated or deleted service
+func configChangesAudit(oldServices, newServices []ServiceConfig) []AuditEntry {
+	oldMap := make(map[string]ServiceConfig)
+	for _, svc := range oldServices {
+		oldMap[svc.Name] = svc
+	}
+
+	var entries []AuditEntry
+	for _, svc := range newServices {
+		entry := AuditEntry{Actor: ActorWatcher, Service: svc.Name}
+		if old, ok := oldMap[svc.Name]; ok {
+			delete(oldMap, svc.Name)
+			entry.Action = AuditUpdate
+			entry.Changes = configDiff(&old, &svc)
+			if len(entry.Changes) == 1 && entry.Changes[0].Field == "enabled" {
+				entry.Action = AuditDisable
+				if svc.IsEnabled() {
+					entry.Action = AuditEnable
+				}
+			}
+		} else {
+			entry.Action = AuditCreate
+			entry.Changes = configDiff(nil, &svc)
+		}
+		if len(entry.Changes) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	for _, svc := range oldServices {
+		if old, ok := oldMap[svc.Name]; ok {
+			entries = append(entries, AuditEntry{Actor: ActorWatcher, Service: svc.Name, Action: AuditDelete, Changes: configDiff(&old, nil)})
+		}
+	}
+	return entries
+}
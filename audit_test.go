@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog_QueryFiltersNewestFirst(t *testing.T) {
+	audit := NewAuditLog(filepath.Join(t.TempDir(), "logs", "audit.jsonl"))
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	audit.Record(AuditEntry{Time: base, Action: AuditStart, Service: "api", Actor: "admin"})
+	audit.Record(AuditEntry{Time: base.Add(time.Minute), Action: AuditStop, Service: "worker", Actor: "admin"})
+	audit.Record(AuditEntry{Time: base.Add(2 * time.Minute), Action: AuditAutoRestart, Service: "api", Actor: ActorAuto})
+
+	entries, more, err := audit.Query(AuditFilter{Service: "api"}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if more || len(entries) != 2 || entries[0].Action != AuditAutoRestart || entries[1].Action != AuditStart {
+		t.Errorf("Expected the api entries newest first, got %+v (more: %v)", entries, more)
+	}
+
+	entries, _, _ = audit.Query(AuditFilter{Actor: "admin", Since: base.Add(30 * time.Second)}, 0, 10)
+	if len(entries) != 1 || entries[0].Service != "worker" {
+		t.Errorf("Expected only the stop by admin, got %+v", entries)
+	}
+
+	entries, more, _ = audit.Query(AuditFilter{}, 1, 1)
+	if !more || len(entries) != 1 || entries[0].Action != AuditStop {
+		t.Errorf("Expected the second newest entry and more, got %+v (more: %v)", entries, more)
+	}
+	if entries, more, _ := audit.Query(AuditFilter{}, 2, 1); more || len(entries) != 1 || entries[0].Action != AuditStart {
+		t.Errorf("Expected the oldest entry and no more, got %+v (more: %v)", entries, more)
+	}
+
+	// A log that was never written is empty
+	if entries, more, err := NewAuditLog(filepath.Join(t.TempDir(), "none.jsonl")).Query(AuditFilter{}, 0, 10); err != nil || more || len(entries) != 0 {
+		t.Errorf("Expected an empty result, got %v %v %v", entries, more, err)
+	}
+}
+
+func TestAuditLog_RotationAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	audit := NewAuditLog(filepath.Join(dir, "audit.jsonl"))
+	audit.maxSize = 1024
+	audit.keep = 2
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	details := strings.Repeat("x", 200) // About 300 bytes per entry, 3 per file
+	for i := range 20 {
+		audit.Record(AuditEntry{Time: base.Add(time.Duration(i) * time.Second), Action: AuditStart, Service: "api", Actor: "admin", Details: details})
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "audit.jsonl*"))
+	if len(files) != 3 {
+		t.Errorf("Expected the log and two rotated files, got %v", files)
+	}
+	for _, file := range files {
+		if info, _ := os.Stat(file); info.Size() > audit.maxSize {
+			t.Errorf("Expected %s to be at most %d bytes, got %d", file, audit.maxSize, info.Size())
+		}
+	}
+
+	// Entries are read across the files, newest first; the oldest were deleted
+	entries, more, err := audit.Query(AuditFilter{}, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The current file has entries 18-19, the rotated ones 15-17 and 12-14
+	if more || len(entries) != 8 || !entries[0].Time.Equal(base.Add(19*time.Second)) || !entries[7].Time.Equal(base.Add(12*time.Second)) {
+		t.Errorf("Expected the newest 8 entries, got %d (more: %v)", len(entries), more)
+	}
+	entries, more, _ = audit.Query(AuditFilter{}, 4, 3)
+	if !more || len(entries) != 3 || !entries[0].Time.Equal(base.Add(15*time.Second)) {
+		t.Errorf("Expected entries 5-7 across two files, got %+v (more: %v)", entries, more)
+	}
+}
+
+func TestScanLinesBackward(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines")
+	long := strings.Repeat("y", 100*1024) // Spans chunks
+	os.WriteFile(path, []byte("first\n"+long+"\n\nlast"), 0644)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanLinesBackward(file, func(line []byte) bool {
+		lines = append(lines, string(line))
+		return true
+	})
+	if len(lines) != 3 || lines[0] != "last" || lines[1] != long || lines[2] != "first" {
+		t.Errorf("Expected last, the long line and first, got %d lines", len(lines))
+	}
+
+	// Stops once fn returns false
+	count := 0
+	scanLinesBackward(file, func(line []byte) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("Expected one call, got %d", count)
+	}
+}
+
+func TestConfigDiff_ChangedFieldsRedacted(t *testing.T) {
+	before := ServiceConfig{Name: "api", Command: "server --port 80", Env: map[string]string{"TOKEN": "env-secret"},
+		LogSinks: []LogSinkConfig{
+			{Type: "http", URL: "http://logs/?key=url-secret", Headers: map[string]string{"Authorization": "Bearer secret", "X-Key": "header-secret"}},
+			{Type: "syslog", Address: "udp://syslog-secret:514"},
+		}}
+	after := before
+	after.Command = "server --port 8080"
+	after.Workdir = "/srv"
+
+	changes := configDiff(&before, &after)
+	if len(changes) != 2 || changes[0].Field != "command" || changes[0].Before != "server --port 80" ||
+		changes[0].After != "server --port 8080" || changes[1].Field != "workdir" || changes[1].Before != nil {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+
+	created := configDiff(nil, &before)
+	data, _ := json.Marshal(created)
+	for _, secret := range []string{"env-secret", "url-secret", "Bearer secret", "header-secret", "syslog-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %s to be redacted, got %s", secret, data)
+		}
+	}
+	for _, change := range created {
+		if env, ok := change.After.(map[string]any); change.Field == "env" && (!ok || env["TOKEN"] != "<redacted>") {
+			t.Errorf("Expected the environment variable names to be kept, got %v", change.After)
+		}
+	}
+
+	// A changed secret is recorded as a change of its setting, without the values
+	after = before
+	after.Env = map[string]string{"TOKEN": "rotated"}
+	if changes := configDiff(&before, &after); len(changes) != 1 || changes[0].Field != "env" || strings.Contains(fmt.Sprint(changes), "rotated") {
+		t.Errorf("Expected a redacted env change, got %+v", changes)
+	}
+}
+
+func TestConfigManager_AuditsExternalEdits(t *testing.T) {
+	yamlPath := createTempYAML(t, `services:
+  - name: api
+    command: server
+  - name: old
+    command: legacy
+`)
+	audit := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	cm := NewConfigManager(yamlPath)
+	cm.SetAuditLog(audit)
+	if err := cm.loadFromDisk(); err != nil {
+		t.Fatal(err)
+	}
+	listener := &mockConfigListener{}
+
+	// Changes written by the API are audited by the server, not by the watcher
+	if err := cm.SetServiceEnabled("api", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.reloadAndNotify(listener); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _, _ := audit.Query(AuditFilter{}, 0, 10); len(entries) != 0 {
+		t.Errorf("Expected no watcher entries for an API change, got %+v", entries)
+	}
+
+	external := `services:
+  - name: api
+    command: server
+    enabled: true
+  - name: new
+    command: fresh
+`
+	if err := os.WriteFile(yamlPath, []byte(external), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.reloadAndNotify(listener); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _, _ := audit.Query(AuditFilter{Actor: ActorWatcher}, 0, 10)
+	actions := map[string]string{}
+	for _, entry := range entries {
+		actions[entry.Service] = entry.Action
+	}
+	if len(entries) != 3 || actions["api"] != AuditEnable || actions["new"] != AuditCreate || actions["old"] != AuditDelete {
+		t.Errorf("Unexpected watcher entries %+v", entries)
+	}
+}
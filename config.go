@@ -5,34 +5,313 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
 )
 
+// Settings whose values are redacted in the log archive and the audit log: credentials, and
+// URLs and addresses that may contain tokens
+var redactedKeys = map[string]bool{
+	"authorization":       true,
+	"password":            true,
+	"failure_webhook_url": true,
+	"url":                 true,
+	"address":             true,
+}
+
+// Settings whose entries all have their values redacted (environment variables, request headers)
+var redactedMapKeys = map[string]bool{
+	"env":     true,
+	"headers": true,
+}
+
+// redactYAML replaces the values of secret settings in a configuration file with "<redacted>",
+// keeping everything else (including comments)
+func redactYAML(data []byte) ([]byte, error) {
+	file, err := parser.ParseBytes(data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range file.Docs {
+		ast.Walk(redactVisitor{}, doc)
+	}
+	return []byte(file.String()), nil
+}
+
+// redactVisitor redacts the values of redactedKeys and the entries of redactedMapKeys
+type redactVisitor struct{}
+
+func (v redactVisitor) Visit(node ast.Node) ast.Visitor {
+	entry, ok := node.(*ast.MappingValueNode)
+	if !ok || entry.Key == nil || entry.Value == nil {
+		return v
+	}
+	key := strings.ToLower(entry.Key.GetToken().Value)
+	switch {
+	case redactedKeys[key]:
+		redactValue(entry)
+	case redactedMapKeys[key]:
+		switch value := entry.Value.(type) {
+		case *ast.MappingNode:
+			for _, child := range value.Values {
+				redactValue(child)
+			}
+		case *ast.MappingValueNode:
+			redactValue(value)
+		default:
+			redactValue(entry)
+		}
+	}
+	return v
+}
+
+// redactValue replaces the value of a mapping entry with "<redacted>"
+func redactValue(entry *ast.MappingValueNode) {
+	if _, ok := entry.Value.(*ast.NullNode); ok {
+		return
+	}
+	if node, err := yaml.ValueToNode("<redacted>"); err == nil {
+		entry.Replace(node)
+	}
+}
+
 // ============================================================================
 // Configuration Structures
 // ============================================================================
 
 // GlobalConfig represents global service manager settings
 type GlobalConfig struct {
-	Host              string `yaml:"host,omitempty"`
-	Port              int    `yaml:"port,omitempty"`
-	FailureWebhookURL string `yaml:"failure_webhook_url,omitempty"`
-	FailureRetries    int    `yaml:"failure_retries,omitempty"` // Number of consecutive failures before webhook triggers
-	Authorization     string `yaml:"authorization,omitempty"`   // BasicAuth credentials in format "username:password"
+	Host              string       `yaml:"host,omitempty"`
+	Port              int          `yaml:"port,omitempty"`
+	FailureWebhookURL string       `yaml:"failure_webhook_url,omitempty"`
+	FailureRetries    int          `yaml:"failure_retries,omitempty"` // Number of consecutive failures before webhook triggers
+	Authorization     string       `yaml:"authorization,omitempty"`   // BasicAuth credentials in format "username:password" (an admin user)
+	Users             []UserConfig `yaml:"users,omitempty"`           // Accounts with roles and optional per-service grants
+
+	LogDir        string             `yaml:"log_dir,omitempty"`         // Directory for logs and run history (default: logs)
+	LogFileFormat string             `yaml:"log_file_format,omitempty"` // Default on-disk log line format: raw, prefixed or json (default: raw)
+	LogRotation   *LogRotationConfig `yaml:"log_rotation,omitempty"`    // Default log rotation for all services (nil = never rotate)
+	LogSinks      []LogSinkConfig    `yaml:"log_sinks,omitempty"`       // Forward the output of all services to syslog or HTTP endpoints
+}
+
+const defaultLogDir = "logs"
+
+// EffectiveLogDir returns the configured log directory (default: logs)
+func (gc *GlobalConfig) EffectiveLogDir() string {
+	if gc.LogDir == "" {
+		return defaultLogDir
+	}
+	return gc.LogDir
 }
 
 // ServiceConfig represents a single service configuration
 type ServiceConfig struct {
-	Name        string            `yaml:"name"`
-	Command     string            `yaml:"command"`               // Full command with arguments (e.g. "python -u server.py")
-	StopCommand string            `yaml:"stopCommand,omitempty"` // Optional graceful stop command (e.g. "curl -X POST http://127.0.0.1:8080/shutdown")
-	Workdir     string            `yaml:"workdir,omitempty"`
-	Env         map[string]string `yaml:"env,omitempty"`
-	Enabled     *bool             `yaml:"enabled,omitempty"`  // nil means true for backwards compatibility
-	Schedule    string            `yaml:"schedule,omitempty"` // Cron schedule (empty = continuous service)
+	Name          string             `yaml:"name"`
+	Command       string             `yaml:"command"`               // Full command with arguments (e.g. "python -u server.py")
+	StopCommand   string             `yaml:"stopCommand,omitempty"` // Optional graceful stop command (e.g. "curl -X POST http://127.0.0.1:8080/shutdown")
+	Workdir       string             `yaml:"workdir,omitempty"`
+	Env           map[string]string  `yaml:"env,omitempty"`
+	Enabled       *bool              `yaml:"enabled,omitempty"`         // nil means true for backwards compatibility
+	Schedule      string             `yaml:"schedule,omitempty"`        // Cron schedule (empty = continuous service)
+	CatchUp       bool               `yaml:"catch_up,omitempty"`        // Run a scheduled service once when its last scheduled run was missed (e.g. while the manager was down)
+	Type          string             `yaml:"type,omitempty"`            // Service type: empty (continuous or scheduled), "oneshot" or "manual"
+	After         []string           `yaml:"after,omitempty"`           // Services that must have finished successfully before this one starts
+	OnSuccess     []string           `yaml:"on_success,omitempty"`      // Services to run when this one exits with code 0
+	OnFailure     []string           `yaml:"on_failure,omitempty"`      // Services to run when this one exits with an error
+	RunLogs       *RunLogsConfig     `yaml:"run_logs,omitempty"`        // Write each run to its own log file (nil = disabled)
+	Watch         *WatchConfig       `yaml:"watch,omitempty"`           // Run a scheduled or manual job when watched files change (nil = disabled)
+	LogRotation   *LogRotationConfig `yaml:"log_rotation,omitempty"`    // Overrides the global log rotation (nil = use global)
+	Log           string             `yaml:"log,omitempty"`             // Log mode: empty (separate stdout/stderr files), "combined" or "both"
+	StdoutPath    string             `yaml:"stdout_path,omitempty"`     // Stdout (or combined) log path template, relative to log_dir; supports {name} and {date}
+	StderrPath    string             `yaml:"stderr_path,omitempty"`     // Stderr log path template, relative to log_dir; supports {name} and {date}
+	CombinedPath  string             `yaml:"combined_path,omitempty"`   // Combined log path template for log: both (default: {name}.log)
+	LogFileFormat string             `yaml:"log_file_format,omitempty"` // On-disk log line format: raw, prefixed or json (empty = global default)
+	LogFormat     string             `yaml:"log_format,omitempty"`      // Format of the service's own output: json, logfmt or text (empty = detect JSON lines)
+	LogFields     *LogFieldsConfig   `yaml:"log_fields,omitempty"`      // Keys of the level, message and time fields in structured output
+	LogSinks      []LogSinkConfig    `yaml:"log_sinks,omitempty"`       // Overrides the global log sinks (empty = use global)
+	Alerts        []AlertConfig      `yaml:"alerts,omitempty"`          // Fire the failure webhook when an output line matches a pattern
+	MaxLineSize   string             `yaml:"max_line_size,omitempty"`   // Longer output lines are truncated, e.g. "1MB" (default 64KB)
+	ANSI          string             `yaml:"ansi,omitempty"`            // Escape sequences in output: keep (default), strip or render
+	Labels        []string           `yaml:"labels,omitempty"`          // Labels that users can be granted access by
+}
+
+// UserConfig represents an account of the web UI and API
+type UserConfig struct {
+	Name     string   `yaml:"name"`
+	Password string   `yaml:"password"`
+	Role     string   `yaml:"role,omitempty"`     // viewer (default), operator or admin
+	Services []string `yaml:"services,omitempty"` // Services the user can access, supports * and ? wildcards (empty = all)
+	Labels   []string `yaml:"labels,omitempty"`   // Labels of the services the user can access (empty = all)
+}
+
+// Service output formats (log_format)
+const (
+	OutputFormatJSON   = "json"   // One JSON object per line
+	OutputFormatLogfmt = "logfmt" // key=value pairs
+	OutputFormatText   = "text"   // Unstructured, no fields are extracted
+)
+
+// LogFieldsConfig configures the field keys of structured log lines (empty = common defaults)
+type LogFieldsConfig struct {
+	Level   string `yaml:"level,omitempty"`   // Default: level, lvl, severity
+	Message string `yaml:"message,omitempty"` // Default: msg, message
+	Time    string `yaml:"time,omitempty"`    // Default: time, ts, timestamp
+}
+
+// Log modes
+const (
+	LogModeCombined = "combined" // Write stdout and stderr to a single file
+	LogModeBoth     = "both"     // Write separate stdout/stderr files plus a combined file
+)
+
+// IsCombinedLog returns true if stdout and stderr are written to a single file
+func (sc *ServiceConfig) IsCombinedLog() bool {
+	return sc.Log == LogModeCombined
+}
+
+// HasCombinedLogFile returns true if a combined log file is written next to the separate ones
+func (sc *ServiceConfig) HasCombinedLogFile() bool {
+	return sc.Log == LogModeBoth
+}
+
+// LogRotationConfig configures rotation of the stdout/stderr log files
+type LogRotationConfig struct {
+	MaxSize  string `yaml:"max_size,omitempty"` // Rotate once the file would exceed this size, e.g. "100MB" (empty = no size limit)
+	Daily    bool   `yaml:"daily,omitempty"`    // Rotate when the first line of a new day is written
+	Keep     int    `yaml:"keep,omitempty"`     // Number of rotated files to keep (0 = unlimited)
+	Compress bool   `yaml:"compress,omitempty"` // Gzip rotated files
+}
+
+// MaxSizeBytes returns the parsed max size (0 = unlimited or invalid)
+func (lr *LogRotationConfig) MaxSizeBytes() int64 {
+	if lr.MaxSize == "" {
+		return 0
+	}
+	size, err := parseByteSize(lr.MaxSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// MaxLineBytes returns the parsed maximum output line size (0 = default or invalid)
+func (sc *ServiceConfig) MaxLineBytes() int {
+	if sc.MaxLineSize == "" {
+		return 0
+	}
+	size, err := parseByteSize(sc.MaxLineSize)
+	if err != nil || size <= 0 {
+		return 0
+	}
+	return int(size)
+}
+
+// EffectiveLogRotation returns the service's log rotation, falling back to the global one
+func (sc *ServiceConfig) EffectiveLogRotation(global GlobalConfig) *LogRotationConfig {
+	if sc.LogRotation != nil {
+		return sc.LogRotation
+	}
+	return global.LogRotation
+}
+
+// EffectiveLogSinks returns the log sinks for the service (service setting overrides global)
+func (sc *ServiceConfig) EffectiveLogSinks(global GlobalConfig) []LogSinkConfig {
+	if len(sc.LogSinks) > 0 {
+		return sc.LogSinks
+	}
+	return global.LogSinks
+}
+
+// Log sink types
+const (
+	LogSinkSyslog = "syslog" // RFC 5424 over a unix socket or UDP
+	LogSinkHTTP   = "http"   // Batched HTTP push
+)
+
+// LogSinkConfig configures a destination to which service output is forwarded
+type LogSinkConfig struct {
+	Type          string            `yaml:"type"`                     // syslog or http
+	Address       string            `yaml:"address,omitempty"`        // syslog: unix socket path (default /dev/log), unix:///path or udp://host:port
+	Facility      string            `yaml:"facility,omitempty"`       // syslog: facility name (default: user)
+	URL           string            `yaml:"url,omitempty"`            // http: endpoint receiving the batches
+	Format        string            `yaml:"format,omitempty"`         // http: json (default), loki or elastic
+	Headers       map[string]string `yaml:"headers,omitempty"`        // http: extra request headers (e.g. Authorization)
+	BatchSize     int               `yaml:"batch_size,omitempty"`     // http: maximum lines per request (default: 100)
+	FlushInterval string            `yaml:"flush_interval,omitempty"` // http: maximum time a line waits for its batch (default: 1s)
+	BufferSize    int               `yaml:"buffer_size,omitempty"`    // Lines queued while the destination is unavailable, oldest dropped first (default: 10000)
+}
+
+// RunLogsConfig configures per-run log files (logs/{name}/runs/{run-id}.log)
+type RunLogsConfig struct {
+	Keep   int    `yaml:"keep,omitempty"`    // Number of run log files to keep (0 = unlimited)
+	MaxAge string `yaml:"max_age,omitempty"` // Delete run log files older than this duration, e.g. "720h" (empty = unlimited)
+}
+
+// MaxAgeDuration returns the parsed max age (0 = unlimited or invalid)
+func (rc *RunLogsConfig) MaxAgeDuration() time.Duration {
+	if rc.MaxAge == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(rc.MaxAge)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+const defaultWatchDebounce = time.Second
+
+// WatchConfig configures the file-change trigger of a job
+type WatchConfig struct {
+	Paths    []string `yaml:"paths"`              // Files, directories or glob patterns (relative to workdir)
+	Debounce string   `yaml:"debounce,omitempty"` // Wait until files have been quiet this long, e.g. "2s" (default 1s)
+}
+
+// DebounceDuration returns the parsed debounce duration (default 1s if empty or invalid)
+func (wc *WatchConfig) DebounceDuration() time.Duration {
+	if wc.Debounce == "" {
+		return defaultWatchDebounce
+	}
+	d, err := time.ParseDuration(wc.Debounce)
+	if err != nil || d < 0 {
+		return defaultWatchDebounce
+	}
+	return d
+}
+
+// AlertConfig fires the failure webhook when an output line matches a pattern
+type AlertConfig struct {
+	Pattern  string `yaml:"pattern"`            // Regular expression matched against each line
+	Stream   string `yaml:"stream,omitempty"`   // stdout or stderr (empty = both)
+	Cooldown string `yaml:"cooldown,omitempty"` // Minimum time between two alerts of this pattern, e.g. "10m" (default 5m)
+	Context  *int   `yaml:"context,omitempty"`  // Lines before and after the match sent with the alert (default 5)
+}
+
+// CooldownDuration returns the parsed cooldown (default 5m if empty or invalid)
+func (ac *AlertConfig) CooldownDuration() time.Duration {
+	if ac.Cooldown == "" {
+		return defaultAlertCooldown
+	}
+	d, err := time.ParseDuration(ac.Cooldown)
+	if err != nil || d < 0 {
+		return defaultAlertCooldown
+	}
+	return d
+}
+
+// ContextLines returns the number of context lines (default 5)
+func (ac *AlertConfig) ContextLines() int {
+	if ac.Context == nil || *ac.Context < 0 {
+		return defaultAlertContext
+	}
+	return *ac.Context
 }
 
 // IsEnabled returns true if the service is enabled (nil means enabled for backwards compatibility)
@@ -43,11 +322,185 @@ func (sc *ServiceConfig) IsEnabled() bool {
 	return *sc.Enabled
 }
 
+// Service types
+const (
+	ServiceTypeOneshot = "oneshot" // Runs once when the manager starts (or when enabled), never restarted
+	ServiceTypeManual  = "manual"  // Never started automatically, only via run-now
+)
+
+// isValidServiceType returns true if the type is empty or a known service type
+func isValidServiceType(t string) bool {
+	return t == "" || t == ServiceTypeOneshot || t == ServiceTypeManual
+}
+
+// serviceProblems returns the services that can't be started automatically because of an
+// invalid type, a log path inside the run log directory, an "after" dependency that doesn't
+// exist, a cycle of "after" dependencies or a dependency with one of these problems, mapped
+// to the reason
+func serviceProblems(services []ServiceConfig, global GlobalConfig) map[string]string {
+	problems := make(map[string]string)
+	byName := make(map[string]ServiceConfig, len(services))
+	for _, svc := range services {
+		byName[svc.Name] = svc
+	}
+
+	for _, svc := range services {
+		if !isValidServiceType(svc.Type) {
+			problems[svc.Name] = fmt.Sprintf("invalid type %q", svc.Type)
+			continue
+		}
+		if field, path := svc.logPathInRunLogDir(global); field != "" {
+			problems[svc.Name] = fmt.Sprintf("%s %q is inside the run log directory %s", field, path, svc.runLogDir(global))
+			continue
+		}
+		for _, dep := range svc.After {
+			if _, exists := byName[dep]; !exists {
+				problems[svc.Name] = fmt.Sprintf("after %q, which doesn't exist", dep)
+				break
+			}
+		}
+	}
+
+	// Depth-first search for cycles; every service of a cycle gets the problem
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range byName[name].After {
+			if _, exists := byName[dep]; !exists {
+				continue
+			}
+			switch state[dep] {
+			case visiting:
+				cycle := path[slices.Index(path, dep):]
+				for _, member := range cycle {
+					if _, exists := problems[member]; !exists {
+						problems[member] = fmt.Sprintf("cycle of after dependencies (%s -> %s)", strings.Join(cycle, " -> "), dep)
+					}
+				}
+			case 0:
+				visit(dep)
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+	for _, svc := range services {
+		if state[svc.Name] == 0 {
+			visit(svc.Name)
+		}
+	}
+
+	// Services after one of these can't start either
+	for changed := true; changed; {
+		changed = false
+		for _, svc := range services {
+			if _, exists := problems[svc.Name]; exists {
+				continue
+			}
+			for _, dep := range svc.After {
+				if _, exists := problems[dep]; exists {
+					problems[svc.Name] = fmt.Sprintf("after %q, which can't be started", dep)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// logPathTemplate resolves a configured log path (relative to the log directory) and expands {name}.
+// {date} is left for the log file to expand, so dated files change while the service runs.
+func (sc *ServiceConfig) logPathTemplate(global GlobalConfig, configured, defaultName string) string {
+	path := configured
+	if path == "" {
+		path = defaultName
+	}
+	path = strings.ReplaceAll(path, "{name}", sc.Name)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(global.EffectiveLogDir(), path)
+	}
+	return path
+}
+
+// runLogDir returns the directory holding the per-run log files ({log_dir}/{name}/runs)
+func (sc *ServiceConfig) runLogDir(global GlobalConfig) string {
+	return filepath.Join(global.EffectiveLogDir(), sc.Name, "runs")
+}
+
+// logPathInRunLogDir returns the first configured log path that resolves inside the run log
+// directory, where run log retention would delete it
+func (sc *ServiceConfig) logPathInRunLogDir(global GlobalConfig) (field, path string) {
+	runDir, err := filepath.Abs(sc.runLogDir(global))
+	if err != nil {
+		return "", ""
+	}
+	for _, p := range []struct{ field, path string }{
+		{"stdout_path", sc.StdoutPath},
+		{"stderr_path", sc.StderrPath},
+		{"combined_path", sc.CombinedPath},
+	} {
+		if p.path == "" {
+			continue
+		}
+		resolved, err := filepath.Abs(sc.logPathTemplate(global, p.path, ""))
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(runDir, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return p.field, p.path
+		}
+	}
+	return "", ""
+}
+
 // IsScheduled returns true if the service has a cron schedule
 func (sc *ServiceConfig) IsScheduled() bool {
 	return sc.Schedule != ""
 }
 
+// IsOneshot returns true if the service runs once and is never restarted
+func (sc *ServiceConfig) IsOneshot() bool {
+	return sc.Type == ServiceTypeOneshot
+}
+
+// IsManual returns true if the service is only ever started on demand
+func (sc *ServiceConfig) IsManual() bool {
+	return sc.Type == ServiceTypeManual
+}
+
+// IsContinuous returns true if the service is long-running and restarted when it exits
+func (sc *ServiceConfig) IsContinuous() bool {
+	return !sc.IsScheduled() && !sc.IsOneshot() && !sc.IsManual()
+}
+
+// IsWatched returns true if the service is a scheduled or manual job with a file-change trigger
+func (sc *ServiceConfig) IsWatched() bool {
+	return sc.Watch != nil && len(sc.Watch.Paths) > 0 && (sc.IsScheduled() || sc.IsManual())
+}
+
+// Kind returns a human-readable service kind for log messages
+func (sc *ServiceConfig) Kind() string {
+	switch {
+	case sc.IsScheduled():
+		return "scheduled"
+	case sc.IsOneshot():
+		return "oneshot"
+	case sc.IsManual():
+		return "manual"
+	default:
+		return "continuous"
+	}
+}
+
 // RootConfig wraps both global config and services in services.yaml
 type RootConfig struct {
 	GlobalConfig `yaml:",inline"` // Embed global config at top level
@@ -118,6 +571,11 @@ type ConfigManager struct {
 	reloadCooldown time.Duration
 	lastReload     time.Time
 
+	// Auditing: file contents written by the API are audited by the server, other changes
+	// are external edits recorded by the watcher
+	audit         *AuditLog
+	savedChecksum string
+
 	mu sync.RWMutex
 }
 
@@ -174,6 +632,13 @@ func (cm *ConfigManager) StartWatching(ctx context.Context, listener ConfigListe
 	return nil
 }
 
+// SetAuditLog sets the log that records external edits of the services
+func (cm *ConfigManager) SetAuditLog(audit *AuditLog) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.audit = audit
+}
+
 // Stop stops the file watcher
 func (cm *ConfigManager) Stop() {
 	close(cm.stopChan)
@@ -241,6 +706,13 @@ func (cm *ConfigManager) reloadAndNotify(listener ConfigListener) error {
 	// So it correctly represents the OLD state before the file change
 	toKill := calculateServicesToKill(cm.services, rootConfig.Services)
 
+	// Changes not written by the API were made by editing the file
+	if checksum != cm.savedChecksum {
+		for _, entry := range configChangesAudit(cm.services, rootConfig.Services) {
+			cm.audit.Record(entry)
+		}
+	}
+
 	// Update internal state
 	cm.comments = newComments
 	cm.services = rootConfig.Services
@@ -453,6 +925,19 @@ func (cm *ConfigManager) ServiceCount() int {
 	return len(cm.services)
 }
 
+// RedactedYAML returns the configuration file with credentials, webhook and sink URLs, sink
+// headers and environment values redacted
+func (cm *ConfigManager) RedactedYAML() ([]byte, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	data, err := os.ReadFile(cm.yamlPath)
+	if err != nil {
+		return nil, err
+	}
+	return redactYAML(data)
+}
+
 // triggerReload sends a signal to reload immediately
 func (cm *ConfigManager) triggerReload() {
 	select {
@@ -543,6 +1028,7 @@ func (cm *ConfigManager) saveToDisk(servicesToSave []ServiceConfig) error {
 
 	// Don't update lastModTime/checksum here - let the watcher detect it
 	// This ensures consistent behavior between API and file changes
+	cm.savedChecksum = fmt.Sprintf("%x", sha256.Sum256(data))
 
 	return nil
 }
@@ -593,7 +1079,41 @@ func calculateServicesToKill(oldServices, newServices []ServiceConfig) []string
 func serviceConfigsEqual(a, b ServiceConfig) bool {
 	if a.Name != b.Name || a.Command != b.Command ||
 		a.Workdir != b.Workdir || a.Schedule != b.Schedule ||
-		a.IsEnabled() != b.IsEnabled() {
+		a.Type != b.Type || a.IsEnabled() != b.IsEnabled() ||
+		a.Log != b.Log || a.StdoutPath != b.StdoutPath || a.StderrPath != b.StderrPath ||
+		a.LogFileFormat != b.LogFileFormat || a.CombinedPath != b.CombinedPath ||
+		a.LogFormat != b.LogFormat || a.MaxLineSize != b.MaxLineSize ||
+		a.ANSI != b.ANSI || a.CatchUp != b.CatchUp {
+		return false
+	}
+
+	if !slices.Equal(a.After, b.After) ||
+		!slices.Equal(a.OnSuccess, b.OnSuccess) ||
+		!slices.Equal(a.OnFailure, b.OnFailure) {
+		return false
+	}
+
+	if !runLogsConfigsEqual(a.RunLogs, b.RunLogs) {
+		return false
+	}
+
+	if !watchConfigsEqual(a.Watch, b.Watch) {
+		return false
+	}
+
+	if !logRotationConfigsEqual(a.LogRotation, b.LogRotation) {
+		return false
+	}
+
+	if !logFieldsConfigsEqual(a.LogFields, b.LogFields) {
+		return false
+	}
+
+	if !logSinkConfigsEqual(a.LogSinks, b.LogSinks) {
+		return false
+	}
+
+	if !alertConfigsEqual(a.Alerts, b.Alerts) {
 		return false
 	}
 
@@ -608,3 +1128,62 @@ func serviceConfigsEqual(a, b ServiceConfig) bool {
 
 	return true
 }
+
+// runLogsConfigsEqual compares two optional run log configs for equality
+func runLogsConfigsEqual(a, b *RunLogsConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// logRotationConfigsEqual compares two optional log rotation configs for equality
+func logRotationConfigsEqual(a, b *LogRotationConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// alertConfigsEqual compares two alert lists for equality
+func alertConfigsEqual(a, b []AlertConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Pattern != b[i].Pattern || a[i].Stream != b[i].Stream || a[i].Cooldown != b[i].Cooldown ||
+			a[i].ContextLines() != b[i].ContextLines() {
+			return false
+		}
+	}
+	return true
+}
+
+// watchConfigsEqual compares two optional watch configs for equality
+func watchConfigsEqual(a, b *WatchConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Debounce == b.Debounce && slices.Equal(a.Paths, b.Paths)
+}
+
+// logFieldsConfigsEqual compares two optional log field configs for equality
+func logFieldsConfigsEqual(a, b *LogFieldsConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// logSinkConfigsEqual compares two log sink lists for equality
+func logSinkConfigsEqual(a, b []LogSinkConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].key() != b[i].key() {
+			return false
+		}
+	}
+	return true
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/goccy/go-yaml"
 )
 
 // ============================================================================
@@ -191,6 +194,57 @@ func TestServiceConfig_IsScheduled(t *testing.T) {
 	}
 }
 
+func TestServiceConfig_ServiceKinds(t *testing.T) {
+	continuous := ServiceConfig{Name: "web", Command: "server"}
+	scheduled := ServiceConfig{Name: "job", Command: "backup", Schedule: "0 2 * * *"}
+	oneshot := ServiceConfig{Name: "setup", Command: "migrate", Type: ServiceTypeOneshot}
+	manual := ServiceConfig{Name: "reindex", Command: "reindex", Type: ServiceTypeManual}
+
+	if !continuous.IsContinuous() || continuous.Kind() != "continuous" {
+		t.Errorf("Expected continuous service, got kind %q", continuous.Kind())
+	}
+	if scheduled.IsContinuous() || scheduled.Kind() != "scheduled" {
+		t.Errorf("Expected scheduled service, got kind %q", scheduled.Kind())
+	}
+	if !oneshot.IsOneshot() || oneshot.IsContinuous() || oneshot.Kind() != "oneshot" {
+		t.Errorf("Expected oneshot service, got kind %q", oneshot.Kind())
+	}
+	if !manual.IsManual() || manual.IsContinuous() || manual.Kind() != "manual" {
+		t.Errorf("Expected manual service, got kind %q", manual.Kind())
+	}
+}
+
+func TestServiceProblems(t *testing.T) {
+	problems := serviceProblems([]ServiceConfig{
+		{Name: "web", Command: "server", After: []string{"setup"}},
+		{Name: "setup", Command: "migrate", Type: ServiceTypeOneshot},
+		{Name: "typo", Command: "x", Type: "oneshoot"},
+		{Name: "orphan", Command: "x", After: []string{"missing"}},
+		{Name: "a", Command: "x", After: []string{"b"}},
+		{Name: "b", Command: "x", After: []string{"c"}},
+		{Name: "c", Command: "x", After: []string{"a"}},
+		{Name: "self", Command: "x", After: []string{"self"}},
+		{Name: "behind-cycle", Command: "x", After: []string{"a"}},
+		{Name: "dated", Command: "x", StdoutPath: "{name}/{date}.log", StderrPath: "{name}/stderr.log"},
+		{Name: "in-runs", Command: "x", StdoutPath: "{name}/runs/{date}.log"},
+		{Name: "abs-runs", Command: "x", StderrPath: "/var/log/sm/abs-runs/runs/err.log"},
+	}, GlobalConfig{LogDir: "/var/log/sm"})
+
+	for _, name := range []string{"typo", "orphan", "a", "b", "c", "self", "behind-cycle", "in-runs", "abs-runs"} {
+		if _, exists := problems[name]; !exists {
+			t.Errorf("Expected a problem for %s", name)
+		}
+	}
+	for _, name := range []string{"web", "setup", "dated"} {
+		if problem, exists := problems[name]; exists {
+			t.Errorf("Expected no problem for %s, got %q", name, problem)
+		}
+	}
+	if !strings.Contains(problems["typo"], "oneshoot") || !strings.Contains(problems["orphan"], "missing") {
+		t.Errorf("Expected the reasons to name the invalid values, got %q and %q", problems["typo"], problems["orphan"])
+	}
+}
+
 // ============================================================================
 // ConfigManager Basic Operations Tests
 // ============================================================================
@@ -210,6 +264,60 @@ func TestNewConfigManager(t *testing.T) {
 	}
 }
 
+func TestConfigManager_RedactedYAML(t *testing.T) {
+	content := `authorization: admin:secret # the admin
+failure_webhook_url: https://hooks.example.com/T0/B0/webhook-token
+users:
+  - name: contractor
+    password: hunter2
+  - password: swordfish
+    name: ops
+log_sinks:
+  - type: http
+    url: https://logs.example.com/push?api_key=sink-token
+    headers:
+      Authorization: Bearer bearer-token
+      X-Api-Key: header-token
+  - type: syslog
+    address: udp://syslog-host:514
+services:
+  - name: web
+    command: ./web
+    env:
+      DATABASE_URL: postgres://user:db-password@db/app
+      DEBUG: "1"
+  - name: worker
+    command: ./worker
+    env: {API_TOKEN: flow-token}
+    log_sinks:
+      - type: http
+        url: >-
+          https://logs.example.com/folded-token
+`
+	cm := NewConfigManager(createTempYAML(t, content))
+
+	data, err := cm.RedactedYAML()
+	if err != nil {
+		t.Fatalf("RedactedYAML failed: %v", err)
+	}
+	for _, secret := range []string{"secret", "webhook-token", "hunter2", "swordfish", "sink-token", "bearer-token",
+		"header-token", "syslog-host", "db-password", "flow-token", "folded-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %s to be redacted, got:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "authorization: <redacted>") || !strings.Contains(string(data), "name: contractor") ||
+		!strings.Contains(string(data), "command: ./web") || !strings.Contains(string(data), "DEBUG: <redacted>") {
+		t.Errorf("Expected the rest of the configuration to be kept, got:\n%s", data)
+	}
+
+	// The redacted file is still valid configuration
+	var root RootConfig
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Services) != 2 || root.Services[1].Env["API_TOKEN"] != "<redacted>" {
+		t.Errorf("Expected valid YAML, got %v: %+v", err, root.Services)
+	}
+}
+
 func TestConfigManager_AddService(t *testing.T) {
 	content := `services: []`
 	yamlPath := createTempYAML(t, content)
@@ -868,6 +976,37 @@ func TestServiceConfigsEqual_DifferentSchedule(t *testing.T) {
 	}
 }
 
+func TestServiceConfigsEqual_DifferentRunLogs(t *testing.T) {
+	a := ServiceConfig{Name: "test", Command: "echo", RunLogs: &RunLogsConfig{Keep: 10}}
+	b := ServiceConfig{Name: "test", Command: "echo", RunLogs: &RunLogsConfig{Keep: 20}}
+	c := ServiceConfig{Name: "test", Command: "echo"}
+
+	if serviceConfigsEqual(a, b) {
+		t.Error("Expected configs with different run log retention to be unequal")
+	}
+	if serviceConfigsEqual(a, c) {
+		t.Error("Expected configs with and without run logs to be unequal")
+	}
+}
+
+func TestServiceConfigsEqual_DifferentType(t *testing.T) {
+	a := ServiceConfig{Name: "test", Command: "echo"}
+	b := ServiceConfig{Name: "test", Command: "echo", Type: ServiceTypeOneshot}
+
+	if serviceConfigsEqual(a, b) {
+		t.Error("Expected configs with different types to be unequal")
+	}
+}
+
+func TestServiceConfigsEqual_DifferentAfter(t *testing.T) {
+	a := ServiceConfig{Name: "test", Command: "echo", After: []string{"setup"}}
+	b := ServiceConfig{Name: "test", Command: "echo", After: []string{"migrate"}}
+
+	if serviceConfigsEqual(a, b) {
+		t.Error("Expected configs with different dependencies to be unequal")
+	}
+}
+
 // ============================================================================
 // File Persistence Tests
 // ============================================================================
@@ -1249,3 +1388,50 @@ func TestConfigManager_Regression_ModifyThenDisable(t *testing.T) {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+func TestServiceConfigsEqual_DifferentWatch(t *testing.T) {
+	a := ServiceConfig{Name: "import", Command: "import", Type: ServiceTypeManual, Watch: &WatchConfig{Paths: []string{"inbox"}}}
+	b := ServiceConfig{Name: "import", Command: "import", Type: ServiceTypeManual, Watch: &WatchConfig{Paths: []string{"inbox"}, Debounce: "5s"}}
+	c := ServiceConfig{Name: "import", Command: "import", Type: ServiceTypeManual}
+
+	if serviceConfigsEqual(a, b) {
+		t.Error("Expected configs with different debounce to be different")
+	}
+	if serviceConfigsEqual(a, c) {
+		t.Error("Expected configs with and without watch to be different")
+	}
+	if !a.IsWatched() || c.IsWatched() {
+		t.Error("Expected only the config with watch paths to be watched")
+	}
+}
+
+func TestServiceConfigsEqual_DifferentChains(t *testing.T) {
+	a := ServiceConfig{Name: "extract", Command: "extract", OnSuccess: []string{"transform"}}
+	b := ServiceConfig{Name: "extract", Command: "extract", OnSuccess: []string{"transform"}, OnFailure: []string{"alert"}}
+
+	if serviceConfigsEqual(a, b) {
+		t.Error("Expected configs with different on_failure to be different")
+	}
+	if !serviceConfigsEqual(a, a) {
+		t.Error("Expected identical configs to be equal")
+	}
+}
+
+func TestServiceConfig_EffectiveLogRotation(t *testing.T) {
+	global := GlobalConfig{LogRotation: &LogRotationConfig{MaxSize: "100MB", Keep: 7}}
+	inherited := ServiceConfig{Name: "web", Command: "server"}
+	override := ServiceConfig{Name: "web", Command: "server", LogRotation: &LogRotationConfig{Daily: true}}
+
+	if got := inherited.EffectiveLogRotation(global); got != global.LogRotation {
+		t.Errorf("Expected global rotation, got %+v", got)
+	}
+	if got := override.EffectiveLogRotation(global); got != override.LogRotation {
+		t.Errorf("Expected service rotation, got %+v", got)
+	}
+	if got := global.LogRotation.MaxSizeBytes(); got != 100<<20 {
+		t.Errorf("Expected 100MB, got %d", got)
+	}
+	if serviceConfigsEqual(inherited, override) {
+		t.Error("Expected configs with different log rotation to be different")
+	}
+}
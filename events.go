@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published on the event bus
+const (
+	EventStarted        = "started"         // A service process started (Trigger tells why)
+	EventExited         = "exited"          // A service process exited
+	EventRestarted      = "restarted"       // A service was restarted (manually or automatically after a crash)
+	EventEnabled        = "enabled"         // A service was enabled in the configuration
+	EventDisabled       = "disabled"        // A service was disabled in the configuration
+	EventCreated        = "created"         // A service was added to the configuration
+	EventUpdated        = "updated"         // A service's configuration changed (the service was recreated)
+	EventRemoved        = "removed"         // A service was removed from the configuration
+	EventConfigReloaded = "config_reloaded" // The services configuration was applied
+	EventDropped        = "dropped"         // A subscriber fell behind and missed events
+)
+
+// Event is a state change of a service or the manager
+type Event struct {
+	Type     string    `json:"type"`
+	Service  string    `json:"service,omitempty"`
+	Time     time.Time `json:"time"`
+	PID      int       `json:"pid,omitempty"`
+	Trigger  string    `json:"trigger,omitempty"`  // Started: what started the run
+	ExitCode *int      `json:"exitCode,omitempty"` // Exited: exit code of the process
+	Dropped  int       `json:"dropped,omitempty"`  // Dropped: number of missed events
+}
+
+// EventBus delivers events to all subscribers. Like the log Broadcaster it never blocks the
+// publisher: subscribers that fall behind miss events and then receive a "dropped" event.
+type EventBus struct {
+	clients map[chan Event]int // Events dropped for the client since its last delivered event
+	mu      sync.Mutex
+}
+
+// NewEventBus creates a new event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		clients: make(map[chan Event]int),
+	}
+}
+
+// Subscribe adds a new client channel
+func (b *EventBus) Subscribe() chan Event {
+	ch := make(chan Event, 100)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clients[ch] = 0
+	return ch
+}
+
+// Unsubscribe removes a client channel and closes it
+func (b *EventBus) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[ch]; ok {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+// Publish sends an event to all subscribers (a nil bus ignores events)
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch, dropped := range b.clients {
+		if dropped > 0 {
+			select {
+			case ch <- Event{Type: EventDropped, Time: event.Time, Dropped: dropped}:
+				dropped = 0
+			default:
+			}
+		}
+
+		if dropped == 0 {
+			select {
+			case ch <- event:
+			default:
+				dropped++
+			}
+		} else {
+			dropped++
+		}
+		b.clients[ch] = dropped
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestEventBus_DroppedEvent(t *testing.T) {
+	b := NewEventBus()
+	ch := b.Subscribe()
+	defer b.Unsubscribe(ch)
+
+	for range cap(ch) + 2 {
+		b.Publish(Event{Type: EventStarted, Service: "svc"})
+	}
+	for range cap(ch) {
+		if event := <-ch; event.Time.IsZero() {
+			t.Fatal("Expected the event time to be set")
+		}
+	}
+	b.Publish(Event{Type: EventExited, Service: "svc"})
+
+	if event := <-ch; event.Type != EventDropped || event.Dropped != 2 {
+		t.Errorf("Expected dropped event for 2 events, got %+v", event)
+	}
+	if event := <-ch; event.Type != EventExited {
+		t.Errorf("Expected the new event after the dropped event, got %+v", event)
+	}
+
+	// A nil bus ignores events
+	var none *EventBus
+	none.Publish(Event{Type: EventStarted})
+}
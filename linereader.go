@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	defaultMaxLineSize = 64 * 1024
+	partialLineDelay   = 500 * time.Millisecond // Output without a newline is flushed after this idle time
+	pipeReadSize       = 32 * 1024
+)
+
+// lineReader splits process output into lines. Unlike bufio.Scanner it never gives up on long
+// lines: lines longer than maxSize are truncated (the rest of the line is discarded and a marker
+// appended), and output without a trailing newline (progress bars, prompts) is emitted once the
+// pipe has been idle for a short while.
+type lineReader struct {
+	r       io.Reader
+	maxSize int
+	idle    time.Duration
+}
+
+func newLineReader(r io.Reader, maxSize int) *lineReader {
+	if maxSize <= 0 {
+		maxSize = defaultMaxLineSize
+	}
+	return &lineReader{r: r, maxSize: maxSize, idle: partialLineDelay}
+}
+
+// Run calls emit for every line (without the line ending) until the reader is exhausted.
+// Returns the read error, or nil at EOF.
+func (lr *lineReader) Run(emit func(text string)) error {
+	chunks := make(chan []byte)
+	var readErr error
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, pipeReadSize)
+		for {
+			n, err := lr.r.Read(buf)
+			if n > 0 {
+				chunks <- bytes.Clone(buf[:n])
+			}
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	var line []byte
+	truncated := 0 // Bytes of the current line discarded beyond maxSize
+	flush := func() {
+		text := string(bytes.TrimSuffix(line, []byte("\r")))
+		if truncated > 0 {
+			// Don't leave half a character or escape sequence behind
+			cut := safeCut(text)
+			text = fmt.Sprintf("%s [truncated %d bytes]", cut, truncated+len(text)-len(cut))
+		}
+		emit(text)
+		line = line[:0]
+		truncated = 0
+	}
+
+	idle := time.NewTimer(lr.idle)
+	idle.Stop()
+	defer idle.Stop()
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if len(line) > 0 || truncated > 0 {
+					flush()
+				}
+				return readErr // Safe to read: written before chunks was closed
+			}
+
+			for len(chunk) > 0 {
+				end := bytes.IndexByte(chunk, '\n')
+				part := chunk
+				if end >= 0 {
+					part = chunk[:end]
+				}
+
+				if room := lr.maxSize - len(line); len(part) > room {
+					line = append(line, part[:room]...)
+					truncated += len(part) - room
+				} else {
+					line = append(line, part...)
+				}
+
+				if end < 0 {
+					break
+				}
+				flush()
+				chunk = chunk[end+1:]
+			}
+
+			if len(line) > 0 || truncated > 0 {
+				idle.Reset(lr.idle)
+			} else {
+				idle.Stop()
+			}
+
+		case <-idle.C:
+			// Nothing arrived for a while: emit the partial line, the rest follows as a new line
+			if len(line) > 0 || truncated > 0 {
+				flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAllLines(t *testing.T, r io.Reader, maxSize int) ([]string, error) {
+	t.Helper()
+	var lines []string
+	err := newLineReader(r, maxSize).Run(func(text string) {
+		lines = append(lines, text)
+	})
+	return lines, err
+}
+
+func TestLineReader_LongLines(t *testing.T) {
+	input := strings.Repeat("x", 100) + "\nshort\r\n" + strings.Repeat("é", 10) + "\nno newline"
+	lines, err := readAllLines(t, strings.NewReader(input), 15)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := []string{
+		strings.Repeat("x", 15) + " [truncated 85 bytes]",
+		"short",
+		strings.Repeat("é", 7) + " [truncated 6 bytes]", // Cut rune is dropped, not split
+		"no newline",
+	}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Unexpected lines:\n got %q\nwant %q", lines, want)
+	}
+}
+
+func TestLineReader_BeyondScannerLimit(t *testing.T) {
+	// bufio.Scanner stops at 64KB; the reader keeps going after a long line
+	input := strings.Repeat("y", 200*1024) + "\nafter\n"
+	lines, err := readAllLines(t, strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(lines) != 2 || lines[1] != "after" {
+		t.Fatalf("Expected the line after the long one, got %d lines", len(lines))
+	}
+	if !strings.HasSuffix(lines[0], " [truncated 139264 bytes]") {
+		t.Errorf("Unexpected truncation marker: %q", lines[0][len(lines[0])-40:])
+	}
+}
+
+func TestLineReader_PartialLineFlush(t *testing.T) {
+	pr, pw := io.Pipe()
+	lines := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- newLineReader(pr, 0).Run(func(text string) { lines <- text })
+	}()
+
+	pw.Write([]byte("Continue? [y/N] "))
+	select {
+	case line := <-lines:
+		if line != "Continue? [y/N] " {
+			t.Errorf("Unexpected partial line %q", line)
+		}
+	case <-time.After(5 * partialLineDelay):
+		t.Fatal("Partial line was not flushed after the idle period")
+	}
+
+	pw.Write([]byte("y\n"))
+	if line := <-lines; line != "y" {
+		t.Errorf("Expected the rest as a new line, got %q", line)
+	}
+
+	pw.CloseWithError(errors.New("broken pipe"))
+	if err := <-done; err == nil || err.Error() != "broken pipe" {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+}
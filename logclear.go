@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// ClearLogs empties the logs of a stream ("" = all streams): the log files are truncated, their
+// rotated segments deleted and the buffers reset. A running service keeps logging into the
+// emptied files. With log: combined all streams share one file, so all of them are cleared.
+func (s *Service) ClearLogs(stream string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// No line may be written between truncating the files and resetting the buffers
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+
+	all := stream == "" || s.Config.IsCombinedLog()
+	rotation := s.Config.EffectiveLogRotation(s.global)
+
+	var errs []error
+	if all || stream == "stdout" {
+		errs = append(errs, truncateLog(s.stdoutFile, s.stdoutLogTemplate(), rotation))
+	}
+	if !s.Config.IsCombinedLog() && (all || stream == "stderr") {
+		errs = append(errs, truncateLog(s.stderrFile, s.stderrLogTemplate(), rotation))
+	}
+	if s.Config.HasCombinedLogFile() && (all || stream == "combined") {
+		errs = append(errs, truncateLog(s.combinedFile, s.combinedLogTemplate(), rotation))
+	}
+
+	switch {
+	case all:
+		s.stdoutBuf.Clear("")
+		s.stderrBuf.Clear("")
+		s.combinedBuf.Clear("")
+	case stream == "combined":
+		s.combinedBuf.Clear("")
+	case stream == "stdout":
+		s.stdoutBuf.Clear("")
+		s.combinedBuf.Clear("stdout")
+	case stream == "stderr":
+		s.stderrBuf.Clear("")
+		s.combinedBuf.Clear("stderr")
+	}
+
+	return errors.Join(errs...)
+}
+
+// truncateLog empties a log. If the service is not running (file is nil) the log is opened
+// just for truncating it; logs that don't exist are left alone.
+func truncateLog(file *LogFile, template string, rotation *LogRotationConfig) error {
+	if file == nil {
+		if len(LogSegments(expandLogDate(template, time.Now()))) == 0 {
+			return nil
+		}
+
+		var err error
+		file, err = OpenLogFile(template, rotation)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+	return file.Truncate()
+}
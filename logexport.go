@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// writeLogDownload writes all segments of a log (oldest first, decompressed) to w.
+// With a time range only the lines within it are written, as stored in the file. Lines
+// without a timestamp are selected as described for timeRangeFilter.
+func writeLogDownload(w io.Writer, path, stream string, since, until time.Time) error {
+	filter := timeRangeFilter{since: since, until: until}
+	var prevEnd time.Time
+	for _, seg := range LogSegments(path) {
+		filter.segment(seg, prevEnd)
+		prevEnd = seg.End
+		reader, err := seg.Open()
+		if err != nil {
+			return err
+		}
+
+		if since.IsZero() && until.IsZero() {
+			_, err = io.Copy(w, reader)
+		} else {
+			err = copyLogLinesInRange(w, reader, stream, &filter)
+		}
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyLogLinesInRange copies the lines of r that pass the time range filter
+func copyLogLinesInRange(w io.Writer, r io.Reader, stream string, filter *timeRangeFilter) error {
+	br := bufio.NewReader(r)
+	for {
+		raw, err := br.ReadString('\n')
+		if raw != "" && filter.matches(parseLogLine(raw, stream)) {
+			if _, err := io.WriteString(w, raw); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// LogFilePaths returns the current log files of the service (stdout, stderr and combined, without duplicates)
+func (s *Service) LogFilePaths() []string {
+	var paths []string
+	for _, stream := range []string{"stdout", "stderr", "combined"} {
+		path, err := s.logPathForStream(stream)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// writeLogArchive writes a tar.gz with all log files of the services (including rotated
+// segments, stored as-is under {service}/) and the configuration as services.yaml
+func writeLogArchive(w io.Writer, services []*Service, config []byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	now := time.Now()
+	if config != nil {
+		header := &tar.Header{Name: "services.yaml", Mode: 0644, Size: int64(len(config)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(config); err != nil {
+			return err
+		}
+	}
+
+	for _, svc := range services {
+		for _, path := range svc.LogFilePaths() {
+			for _, seg := range LogSegments(path) {
+				name := filepath.ToSlash(filepath.Join(svc.Config.Name, filepath.Base(seg.Path)))
+				if err := addFileToArchive(tw, name, seg.Path); err != nil {
+					return fmt.Errorf("failed to archive %s: %w", seg.Path, err)
+				}
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addFileToArchive adds a file to the archive. Only the size at the time of the call is
+// archived, so files that are still being written to are cut off consistently.
+func addFileToArchive(tw *tar.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Rotated or pruned in the meantime
+		}
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{Name: name, Mode: 0644, Size: stat.Size(), ModTime: stat.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, file, stat.Size())
+	return err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLogDownload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	lf, err := OpenLogFile(path, &LogRotationConfig{MaxSize: "60B", Compress: true})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		line := LogLine{Time: base.Add(time.Duration(i) * time.Hour), Stream: "stdout", PID: 1, Text: "tick"}
+		lf.WriteString(formatLogLine(line, LogFormatPrefixed))
+		time.Sleep(2 * time.Millisecond) // Distinct segment timestamps
+	}
+	lf.Close()
+	waitLogCompression()
+
+	var full bytes.Buffer
+	if err := writeLogDownload(&full, path, "stdout", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("writeLogDownload failed: %v", err)
+	}
+	if got := strings.Count(full.String(), "\n"); got != 4 {
+		t.Errorf("Expected all 4 lines across segments, got %d:\n%s", got, full.String())
+	}
+
+	var ranged bytes.Buffer
+	if err := writeLogDownload(&ranged, path, "stdout", base.Add(time.Hour), base.Add(2*time.Hour)); err != nil {
+		t.Fatalf("writeLogDownload failed: %v", err)
+	}
+	if got := strings.Count(ranged.String(), "\n"); got != 2 {
+		t.Errorf("Expected 2 lines in the time range, got %d:\n%s", got, ranged.String())
+	}
+}
+
+func TestWriteLogDownload_LinesWithoutTimestamp(t *testing.T) {
+	dir := t.TempDir()
+
+	// Raw logs are selected by the modification time of the file
+	raw := filepath.Join(dir, "raw.log")
+	os.WriteFile(raw, []byte("one\ntwo\n"), 0644)
+	var buf bytes.Buffer
+	if err := writeLogDownload(&buf, raw, "stdout", time.Now().Add(-time.Hour), time.Time{}); err != nil {
+		t.Fatalf("writeLogDownload failed: %v", err)
+	}
+	if buf.String() != "one\ntwo\n" {
+		t.Errorf("Expected the raw lines of the last hour, got %q", buf.String())
+	}
+	buf.Reset()
+	writeLogDownload(&buf, raw, "stdout", time.Now().Add(time.Hour), time.Time{})
+	if buf.Len() != 0 {
+		t.Errorf("Expected no raw lines after the file was last written, got %q", buf.String())
+	}
+
+	// Continuation lines stay with the timestamped line before them
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	prefixed := filepath.Join(dir, "prefixed.log")
+	os.WriteFile(prefixed, []byte(
+		formatLogLine(LogLine{Time: base, Stream: "stdout", Text: "early"}, LogFormatPrefixed)+
+			formatLogLine(LogLine{Time: base.Add(time.Hour), Stream: "stdout", Text: "panic"}, LogFormatPrefixed)+
+			"\tat main.go:12\n"), 0644)
+	buf.Reset()
+	writeLogDownload(&buf, prefixed, "stdout", base.Add(30*time.Minute), time.Time{})
+	if got := buf.String(); strings.Contains(got, "early") || !strings.Contains(got, "panic") || !strings.Contains(got, "main.go:12") {
+		t.Errorf("Expected the late line with its continuation, got %q", got)
+	}
+}
+
+func TestWriteLogArchive(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web"}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	svc.logServiceEvent("Starting")
+	svc.closeLogFiles()
+
+	var archive bytes.Buffer
+	if err := writeLogArchive(&archive, []*Service{svc}, []byte("services: []\n")); err != nil {
+		t.Fatalf("writeLogArchive failed: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&archive)
+	if err != nil {
+		t.Fatalf("Expected a gzip archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[header.Name] = string(data)
+	}
+
+	if files["services.yaml"] != "services: []\n" {
+		t.Errorf("Expected services.yaml in archive, got %v", files)
+	}
+	for _, name := range []string{"web/web-stdout.log", "web/web-stderr.log"} {
+		if !strings.Contains(files[name], "Starting") {
+			t.Errorf("Expected %s to contain the log, got %q", name, files[name])
+		}
+	}
+}
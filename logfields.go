@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels in increasing severity
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// Default field keys of structured log lines (checked in order)
+var (
+	defaultLevelKeys   = []string{"level", "lvl", "severity"}
+	defaultMessageKeys = []string{"msg", "message"}
+	defaultTimeKeys    = []string{"time", "ts", "timestamp"}
+)
+
+// normalizeLogLevel maps common level names and numeric (pino/bunyan) levels to one of
+// logLevels. Unknown levels return "".
+func normalizeLogLevel(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "trace", "trc", "10":
+		return "trace"
+	case "debug", "dbg", "20":
+		return "debug"
+	case "info", "inf", "information", "notice", "30":
+		return "info"
+	case "warn", "wrn", "warning", "40":
+		return "warn"
+	case "error", "err", "50":
+		return "error"
+	case "fatal", "ftl", "critical", "crit", "panic", "alert", "emerg", "emergency", "60":
+		return "fatal"
+	}
+	return ""
+}
+
+// logLevelRank returns the severity of a level (1 = trace ... 6 = fatal, 0 = unknown)
+func logLevelRank(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// hasMinLevel reports whether the line has at least the given level rank (0 matches all lines).
+// Lines without a level never match a level filter.
+func (l LogLine) hasMinLevel(minRank int) bool {
+	return minRank == 0 || logLevelRank(l.Level) >= minRank
+}
+
+// logFieldParser extracts the level, message and time from structured output lines
+type logFieldParser struct {
+	format      string // json, logfmt, text or "" (detect JSON lines)
+	levelKeys   []string
+	messageKeys []string
+	timeKeys    []string
+}
+
+// newLogFieldParser creates a parser for a service's log_format and log_fields
+func newLogFieldParser(format string, fields *LogFieldsConfig) *logFieldParser {
+	p := &logFieldParser{
+		format:      format,
+		levelKeys:   defaultLevelKeys,
+		messageKeys: defaultMessageKeys,
+		timeKeys:    defaultTimeKeys,
+	}
+	if fields != nil {
+		if fields.Level != "" {
+			p.levelKeys = []string{fields.Level}
+		}
+		if fields.Message != "" {
+			p.messageKeys = []string{fields.Message}
+		}
+		if fields.Time != "" {
+			p.timeKeys = []string{fields.Time}
+		}
+	}
+	return p
+}
+
+// annotate sets the level, message and application time of a line from its text
+func (p *logFieldParser) annotate(line *LogLine) {
+	if p == nil || p.format == OutputFormatText {
+		return
+	}
+
+	var fields map[string]any
+	if p.format == OutputFormatLogfmt {
+		fields = parseLogfmt(line.Text)
+	} else if text := strings.TrimSpace(line.Text); strings.HasPrefix(text, "{") {
+		json.Unmarshal([]byte(text), &fields)
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	for _, key := range p.levelKeys {
+		if value, ok := fields[key]; ok {
+			line.Level = normalizeLogLevel(fieldString(value))
+			break
+		}
+	}
+	for _, key := range p.messageKeys {
+		if value, ok := fields[key]; ok {
+			line.Message = fieldString(value)
+			break
+		}
+	}
+	for _, key := range p.timeKeys {
+		if value, ok := fields[key]; ok {
+			line.AppTime = parseFieldTime(value)
+			break
+		}
+	}
+}
+
+// fieldString converts a decoded field value to a string
+func fieldString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// parseFieldTime parses a time field: RFC 3339 strings or Unix timestamps in seconds or milliseconds
+func parseFieldTime(value any) time.Time {
+	var number float64
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}
+		}
+		number = n
+	case float64:
+		number = v
+	default:
+		return time.Time{}
+	}
+
+	if number > 1e12 {
+		number /= 1000 // Milliseconds
+	}
+	sec, frac := math.Modf(number)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// parseLogfmt parses key=value pairs (values may be double-quoted). Returns nil if the text
+// contains no pairs.
+func parseLogfmt(text string) map[string]any {
+	fields := map[string]any{}
+	for i := 0; i < len(text); {
+		// Skip whitespace
+		for i < len(text) && text[i] == ' ' {
+			i++
+		}
+
+		start := i
+		for i < len(text) && text[i] != '=' && text[i] != ' ' {
+			i++
+		}
+		key := text[start:i]
+		if i >= len(text) || text[i] != '=' {
+			continue // Bare word, not a pair
+		}
+		i++
+
+		var value string
+		if i < len(text) && text[i] == '"' {
+			// Quoted value with backslash escapes
+			var sb strings.Builder
+			i++
+			for i < len(text) && text[i] != '"' {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+				}
+				sb.WriteByte(text[i])
+				i++
+			}
+			i++ // Closing quote
+			value = sb.String()
+		} else {
+			start := i
+			for i < len(text) && text[i] != ' ' {
+				i++
+			}
+			value = text[start:i]
+		}
+
+		if key != "" {
+			fields[key] = value
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// errorRate counts error lines over the last minute in per-second buckets
+type errorRate struct {
+	mu      sync.Mutex
+	seconds [60]int64 // Unix second of each bucket
+	counts  [60]int
+}
+
+// Add records an error line at t
+func (r *errorRate) Add(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sec := t.Unix()
+	i := sec % 60
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+// PerMinute returns the number of error lines during the last minute
+func (r *errorRate) PerMinute() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().Unix()
+	total := 0
+	for i := range r.seconds {
+		if now-r.seconds[i] < 60 {
+			total += r.counts[i]
+		}
+	}
+	return total
+}
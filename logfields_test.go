@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFieldParser_Annotate(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		fields      *LogFieldsConfig
+		text        string
+		wantLevel   string
+		wantMessage string
+		wantTime    time.Time
+	}{
+		{
+			name:        "detected json",
+			text:        `{"time":"2024-05-01T12:00:00Z","level":"WARNING","msg":"disk almost full"}`,
+			wantLevel:   "warn",
+			wantMessage: "disk almost full",
+			wantTime:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "numeric level and millisecond time",
+			format:      OutputFormatJSON,
+			text:        `{"level":50,"time":1714564800000,"msg":"request failed"}`,
+			wantLevel:   "error",
+			wantMessage: "request failed",
+			wantTime:    time.Unix(1714564800, 0),
+		},
+		{
+			name:        "custom keys",
+			fields:      &LogFieldsConfig{Level: "sev", Message: "event"},
+			text:        `{"sev":"critical","event":"out of memory","level":"info"}`,
+			wantLevel:   "fatal",
+			wantMessage: "out of memory",
+		},
+		{
+			name:        "logfmt",
+			format:      OutputFormatLogfmt,
+			text:        `ts=2024-05-01T12:00:00Z level=error msg="connection \"db\" lost" retry=true`,
+			wantLevel:   "error",
+			wantMessage: `connection "db" lost`,
+			wantTime:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "text",
+			format: OutputFormatText,
+			text:   `{"level":"error","msg":"ignored"}`,
+		},
+		{
+			name: "plain line",
+			text: "Listening on :8080",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := LogLine{Text: tt.text}
+			newLogFieldParser(tt.format, tt.fields).annotate(&line)
+			if line.Level != tt.wantLevel || line.Message != tt.wantMessage || !line.AppTime.Equal(tt.wantTime) {
+				t.Errorf("got level=%q msg=%q ts=%v, want level=%q msg=%q ts=%v",
+					line.Level, line.Message, line.AppTime, tt.wantLevel, tt.wantMessage, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestLogLine_HasMinLevel(t *testing.T) {
+	minRank := logLevelRank("warn")
+	if !(LogLine{Level: "error"}).hasMinLevel(minRank) || (LogLine{Level: "info"}).hasMinLevel(minRank) {
+		t.Error("Expected levels to be compared by severity")
+	}
+	if (LogLine{}).hasMinLevel(minRank) || !(LogLine{}).hasMinLevel(0) {
+		t.Error("Expected lines without a level to only match without a filter")
+	}
+}
+
+func TestService_CountsErrorsPerMinute(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "api"}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	output := strings.Join([]string{
+		`{"level":"info","msg":"started"}`,
+		`{"level":"error","msg":"failed"}`,
+		`not json`,
+		`{"level":"fatal","msg":"crashed"}`,
+	}, "\n") + "\n"
+	svc.readLogs(strings.NewReader(output), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.closeLogFiles()
+
+	if got := svc.ErrorsPerMinute(); got != 2 {
+		t.Errorf("Expected 2 errors per minute, got %d", got)
+	}
+
+	// Lines read back from the (raw) log file are annotated again
+	restarted := NewService(svc.Config, GlobalConfig{})
+	lines := restarted.GetStdoutBuffer()
+	if len(lines) != 4 || lines[1].Level != "error" || lines[1].Message != "failed" {
+		t.Errorf("Expected reloaded lines to carry their level, got %+v", lines)
+	}
+}
@@ -0,0 +1,771 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const logSegmentTimeFormat = "20060102-150405.000" // Timestamp suffix of rotated log segments
+
+// logSegmentPattern matches the suffix of rotated segments: .YYYYMMDD-HHMMSS.mmm[-N][.gz], where
+// N makes the names of segments rotated within the same millisecond unique
+var logSegmentPattern = regexp.MustCompile(`^\.\d{8}-\d{6}\.\d{3}(-(\d+))?(\.gz)?$`)
+
+// logCompressions tracks the background compression of rotated segments of all logs
+var logCompressions sync.WaitGroup
+
+// waitLogCompression waits until all started compressions finished (e.g. before exiting)
+func waitLogCompression() {
+	logCompressions.Wait()
+}
+
+// logIndex records the virtual byte offset at which each segment starts, so byte offsets
+// into a log stay valid after rotation. It is stored next to the log as {path}.index.
+type logIndex struct {
+	ActiveStart int64            `json:"activeStart"` // Virtual offset of the first byte of the active file
+	Segments    map[string]int64 `json:"segments"`    // Rotated segment file name (without .gz) → virtual start offset
+}
+
+// LogSegment is one file of a (possibly rotated) log, oldest segments first
+type LogSegment struct {
+	Path       string
+	Compressed bool
+	Start      int64     // Virtual offset of the first byte of the segment
+	End        time.Time // Approximate time of the last line: rotation time, or modification time of the active file
+}
+
+// LogFile is an append-only log file that rotates by size and/or day.
+// Rotated segments are renamed to {path}.{timestamp}, optionally gzipped, and pruned
+// to the configured number of files. All methods are safe for concurrent use.
+type LogFile struct {
+	template string // Path template, may contain {date} (the active file switches when the date changes)
+	path     string // Current expansion of the template
+	maxSize  int64
+	daily    bool
+	keep     int
+	compress bool
+
+	file     *os.File
+	size     int64  // Size of the active file
+	day      string // Day (YYYYMMDD) the active file was started, for daily rotation
+	index    logIndex
+	mu       sync.Mutex
+	compDone chan struct{} // Closed when the most recently started compression finished
+	gen      int           // Incremented by Truncate, so pending compressions discard their result
+}
+
+// OpenLogFile opens (or creates) a log file for appending with the given rotation settings
+// (nil = never rotate). A {date} placeholder in the path is expanded to the current date.
+func OpenLogFile(pathTemplate string, rotation *LogRotationConfig) (*LogFile, error) {
+	lf := &LogFile{template: pathTemplate}
+	if rotation != nil {
+		lf.maxSize = rotation.MaxSizeBytes()
+		lf.daily = rotation.Daily
+		lf.keep = rotation.Keep
+		lf.compress = rotation.Compress
+	}
+
+	if err := lf.open(expandLogDate(pathTemplate, time.Now())); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// open makes path the active file
+// Caller must hold the lock (or have exclusive access)
+func (lf *LogFile) open(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	lf.path = path
+	lf.file = file
+	lf.size = stat.Size()
+	lf.day = time.Now().Format("20060102")
+	if stat.Size() > 0 {
+		// An existing file belongs to the day it was last written
+		lf.day = stat.ModTime().Format("20060102")
+	}
+	lf.index = readLogIndex(path)
+	return nil
+}
+
+// Path returns the path of the active file
+func (lf *LogFile) Path() string {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return lf.path
+}
+
+// Write appends p to the log, rotating first if the size or day limit is reached
+func (lf *LogFile) Write(p []byte) (int, error) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	if lf.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	// A dated path template moves on to a new file when the date changes
+	if path := expandLogDate(lf.template, time.Now()); path != lf.path {
+		lf.file.Close()
+		if err := lf.open(path); err != nil {
+			lf.file = nil
+			return 0, err
+		}
+	}
+
+	if lf.needsRotation(int64(len(p))) {
+		if err := lf.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate log %s: %v\n", lf.path, err)
+		}
+	}
+
+	n, err := lf.file.Write(p)
+	lf.size += int64(n)
+	return n, err
+}
+
+// WriteString appends s to the log
+func (lf *LogFile) WriteString(s string) (int, error) {
+	return lf.Write([]byte(s))
+}
+
+// Offset returns the virtual byte offset of the end of the log (stable across rotations)
+func (lf *LogFile) Offset() int64 {
+	if lf == nil {
+		return 0
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return lf.index.ActiveStart + lf.size
+}
+
+// Truncate empties the log: the active file is truncated and all rotated segments are deleted.
+// Virtual offsets continue where the log ended, so earlier offsets simply point to no data.
+func (lf *LogFile) Truncate() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	if lf.file == nil {
+		return os.ErrClosed
+	}
+	if err := lf.file.Truncate(0); err != nil {
+		return err
+	}
+
+	// Segments being compressed are removed by their compression (see compressSegment)
+	lf.gen++
+
+	segments, _ := rotatedLogSegments(lf.path)
+	var removeErr error
+	for _, segment := range segments {
+		if err := os.Remove(segment); err != nil && !os.IsNotExist(err) && removeErr == nil && !lf.compressing(segment) {
+			removeErr = err
+		}
+	}
+
+	lf.index.ActiveStart += lf.size
+	lf.index.Segments = nil
+	lf.size = 0
+	if err := writeLogIndex(lf.path, lf.index); err != nil {
+		return err
+	}
+	return removeErr
+}
+
+// Close closes the active file. Compression of rotated segments continues in the background
+// (see waitLogCompression), so closing never waits for it.
+func (lf *LogFile) Close() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	if lf.file == nil {
+		return nil
+	}
+	err := lf.file.Close()
+	lf.file = nil
+	return err
+}
+
+// needsRotation reports whether writing n more bytes requires a rotation first
+// Caller must hold the lock
+func (lf *LogFile) needsRotation(n int64) bool {
+	if lf.size == 0 {
+		return false
+	}
+	if lf.maxSize > 0 && lf.size+n > lf.maxSize {
+		return true
+	}
+	if lf.daily && time.Now().Format("20060102") != lf.day {
+		return true
+	}
+	return false
+}
+
+// rotate renames the active file to a timestamped segment and starts a new active file
+// Caller must hold the lock
+func (lf *LogFile) rotate() error {
+	if err := lf.file.Close(); err != nil {
+		return err
+	}
+
+	segment := newSegmentPath(lf.path, time.Now())
+	renameErr := os.Rename(lf.path, segment)
+
+	// Always reopen, even if the rename failed, so logging continues
+	file, err := os.OpenFile(lf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		lf.file = nil
+		return err
+	}
+	lf.file = file
+	if renameErr != nil {
+		return renameErr
+	}
+
+	// Record where the rotated segment starts in the virtual stream
+	if lf.index.Segments == nil {
+		lf.index.Segments = make(map[string]int64)
+	}
+	lf.index.Segments[filepath.Base(segment)] = lf.index.ActiveStart
+	lf.index.ActiveStart += lf.size
+	lf.size = 0
+	lf.day = time.Now().Format("20060102")
+
+	if lf.compress {
+		// Compress in the background, one segment after another so pruning sees them in order
+		prev := lf.compDone
+		done := make(chan struct{})
+		lf.compDone = done
+		gen := lf.gen
+		logCompressions.Add(1)
+		go func() {
+			defer logCompressions.Done()
+			defer close(done)
+			if prev != nil {
+				<-prev
+			}
+			lf.compressSegment(segment, gen)
+		}()
+	} else {
+		lf.pruneLocked()
+	}
+
+	return writeLogIndex(lf.path, lf.index)
+}
+
+// newSegmentPath returns an unused name for a segment rotated at t: {path}.{timestamp}, with a
+// -N suffix if a segment was already rotated within the same millisecond
+func newSegmentPath(path string, t time.Time) string {
+	base := path + "." + t.Format(logSegmentTimeFormat)
+	segment := base
+	for n := 1; ; n++ {
+		_, err := os.Stat(segment)
+		_, gzErr := os.Stat(segment + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return segment
+		}
+		segment = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// compressSegment gzips a rotated segment and prunes old segments. If the log was truncated
+// in the meantime (gen changed), the segment and its compressed copy are removed instead.
+func (lf *LogFile) compressSegment(segment string, gen int) {
+	tempPath := segment + ".gz.tmp"
+	err := gzipFile(segment, tempPath)
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	if lf.gen != gen {
+		os.Remove(tempPath)
+		os.Remove(segment)
+		return
+	}
+	if err == nil {
+		err = os.Rename(tempPath, segment+".gz")
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		fmt.Fprintf(os.Stderr, "Failed to compress log segment %s: %v\n", segment, err)
+	} else {
+		os.Remove(segment)
+	}
+	lf.pruneLocked()
+	writeLogIndex(lf.path, lf.index)
+}
+
+// compressing reports whether a segment may still be open for compression
+// Caller must hold the lock
+func (lf *LogFile) compressing(segment string) bool {
+	if lf.compDone == nil || strings.HasSuffix(segment, ".gz") {
+		return false
+	}
+	select {
+	case <-lf.compDone:
+		return false
+	default:
+		return true
+	}
+}
+
+// pruneLocked deletes old segments and forgets them in the index
+// Caller must hold the lock
+func (lf *LogFile) pruneLocked() {
+	if lf.keep <= 0 {
+		return
+	}
+
+	segments, err := rotatedLogSegments(lf.path)
+	if err != nil || len(segments) <= lf.keep {
+		return
+	}
+
+	for _, segment := range segments[:len(segments)-lf.keep] {
+		if err := os.Remove(segment); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Failed to remove log segment %s: %v\n", segment, err)
+			continue
+		}
+		delete(lf.index.Segments, strings.TrimSuffix(filepath.Base(segment), ".gz"))
+	}
+}
+
+// expandLogDate replaces the {date} placeholder of a log path template (YYYY-MM-DD)
+func expandLogDate(template string, t time.Time) string {
+	return strings.ReplaceAll(template, "{date}", t.Format("2006-01-02"))
+}
+
+// gzipFile writes a gzipped copy of a file
+func gzipFile(srcPath, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		src.Close()
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	src.Close()
+	if err != nil {
+		os.Remove(dstPath)
+	}
+	return err
+}
+
+// rotatedLogSegments returns the paths of the rotated segments of a log, oldest first
+func rotatedLogSegments(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	base := filepath.Base(path)
+	var segments []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+		if logSegmentPattern.MatchString(name[len(base):]) {
+			segments = append(segments, filepath.Join(filepath.Dir(path), name))
+		}
+	}
+
+	// Timestamps sort chronologically, then by the -N suffix
+	sort.Slice(segments, func(i, j int) bool {
+		ti, ni := segmentSortKey(filepath.Base(segments[i])[len(base):])
+		tj, nj := segmentSortKey(filepath.Base(segments[j])[len(base):])
+		if ti != tj {
+			return ti < tj
+		}
+		return ni < nj
+	})
+	return segments, nil
+}
+
+// segmentSortKey returns the timestamp and -N suffix of a rotated segment's suffix
+func segmentSortKey(suffix string) (string, int) {
+	match := logSegmentPattern.FindStringSubmatch(suffix)
+	n, _ := strconv.Atoi(match[2])
+	return suffix[1 : 1+len(logSegmentTimeFormat)], n
+}
+
+// LogSegments returns all existing files of a log (rotated segments oldest first, then the active file)
+func LogSegments(path string) []LogSegment {
+	index := readLogIndex(path)
+
+	rotated, _ := rotatedLogSegments(path)
+	segments := make([]LogSegment, 0, len(rotated)+1)
+	for _, segment := range rotated {
+		name := strings.TrimSuffix(filepath.Base(segment), ".gz")
+		end, _ := time.ParseInLocation(logSegmentTimeFormat, name[len(filepath.Base(path))+1:][:len(logSegmentTimeFormat)], time.Local)
+		segments = append(segments, LogSegment{
+			Path:       segment,
+			Compressed: strings.HasSuffix(segment, ".gz"),
+			Start:      index.Segments[name],
+			End:        end,
+		})
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		segments = append(segments, LogSegment{Path: path, Start: index.ActiveStart, End: info.ModTime()})
+	}
+	return segments
+}
+
+// datedLogPaths returns the active files of all dates of a log path template, oldest first.
+// Templates without {date} have a single file.
+func datedLogPaths(template string) []string {
+	if !strings.Contains(template, "{date}") {
+		return []string{template}
+	}
+
+	// Escape glob characters of the template, then match any date
+	quoted := strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]").Replace(template)
+	pattern := strings.ReplaceAll(quoted, "{date}", "[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]")
+	paths, _ := filepath.Glob(pattern)
+	sort.Strings(paths) // Dates sort chronologically
+
+	// Include today's file even before it exists (it may only have rotated segments)
+	if today := expandLogDate(template, time.Now()); !slices.Contains(paths, today) {
+		paths = append(paths, today)
+	}
+	return paths
+}
+
+// Open opens the segment for reading, transparently decompressing gzipped segments
+func (seg LogSegment) Open() (io.ReadCloser, error) {
+	file, err := os.Open(seg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !seg.Compressed {
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipReadCloser{Reader: gz, file: file}, nil
+}
+
+// gzipReadCloser closes both the gzip reader and the underlying file
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// readLogTail returns up to n bytes from the end of a log, reading across rotated segments
+func readLogTail(path string, n int64) []byte {
+	segments := LogSegments(path)
+
+	var chunks [][]byte
+	remaining := n
+	for i := len(segments) - 1; i >= 0 && remaining > 0; i-- {
+		data := readSegmentTail(segments[i], remaining)
+		if len(data) == 0 {
+			continue
+		}
+		chunks = append(chunks, data)
+		remaining -= int64(len(data))
+	}
+
+	// Chunks were collected newest first
+	var result []byte
+	for i := len(chunks) - 1; i >= 0; i-- {
+		result = append(result, chunks[i]...)
+	}
+	return result
+}
+
+// readSegmentTail returns up to n bytes from the end of a single segment
+func readSegmentTail(seg LogSegment, n int64) []byte {
+	if seg.Compressed {
+		// Compressed segments cannot be seeked, read them fully
+		reader, err := seg.Open()
+		if err != nil {
+			return nil
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil && len(data) == 0 {
+			return nil
+		}
+		if int64(len(data)) > n {
+			data = data[int64(len(data))-n:]
+		}
+		return data
+	}
+
+	file, err := os.Open(seg.Path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.Size() == 0 {
+		return nil
+	}
+
+	offset := int64(0)
+	readSize := stat.Size()
+	if readSize > n {
+		offset = readSize - n
+		readSize = n
+	}
+
+	data := make([]byte, readSize)
+	read, err := file.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return nil
+	}
+	return data[:read]
+}
+
+// segmentEnd returns the virtual offset just past the last byte of segments[i]
+func segmentEnd(segments []LogSegment, i int) int64 {
+	seg := segments[i]
+	if i+1 < len(segments) && segments[i+1].Start > seg.Start {
+		return segments[i+1].Start
+	}
+
+	if !seg.Compressed {
+		if stat, err := os.Stat(seg.Path); err == nil {
+			return seg.Start + stat.Size()
+		}
+		return seg.Start
+	}
+
+	// The size of a compressed segment is only known after decompressing it
+	reader, err := seg.Open()
+	if err != nil {
+		return seg.Start
+	}
+	defer reader.Close()
+	n, _ := io.Copy(io.Discard, reader)
+	return seg.Start + n
+}
+
+// readSegmentRange returns up to n bytes of a segment starting at the (segment relative) offset
+func readSegmentRange(seg LogSegment, offset, n int64) []byte {
+	reader, err := seg.Open()
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+
+	if file, ok := reader.(*os.File); ok {
+		data := make([]byte, n)
+		read, err := file.ReadAt(data, offset)
+		if err != nil && err != io.EOF {
+			return nil
+		}
+		return data[:read]
+	}
+
+	// Compressed segments cannot be seeked
+	if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+		return nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(reader, n))
+	return data
+}
+
+// readLogRange returns up to limit bytes of a log starting at a virtual offset, reading across
+// rotated segments. Offsets before the oldest retained segment start at that segment.
+func readLogRange(segments []LogSegment, offset, limit int64) []byte {
+	if len(segments) == 0 || limit <= 0 {
+		return nil
+	}
+	offset = max(offset, segments[0].Start)
+
+	var data []byte
+	for i, seg := range segments {
+		remaining := limit - int64(len(data))
+		if remaining <= 0 {
+			break
+		}
+		pos := offset + int64(len(data))
+		end := segmentEnd(segments, i)
+		if end <= pos {
+			continue
+		}
+		data = append(data, readSegmentRange(seg, max(pos-seg.Start, 0), min(remaining, end-pos))...)
+	}
+	return data
+}
+
+// readLogLineRange reads whole lines of a log, starting with the first line that begins at or
+// after the virtual offset, up to limit bytes. A line longer than limit is returned cut off.
+// It returns the data and the virtual offset of its first byte.
+func readLogLineRange(path string, offset, limit int64) ([]byte, int64) {
+	segments := LogSegments(path)
+	if len(segments) == 0 {
+		return nil, 0
+	}
+
+	var data []byte
+	if offset <= segments[0].Start {
+		offset = segments[0].Start
+		data = readLogRange(segments, offset, limit)
+	} else {
+		// Include the previous byte to know whether offset is at the start of a line
+		data = readLogRange(segments, offset-1, limit+1)
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			return nil, offset
+		}
+		data = data[idx+1:]
+		offset += int64(idx)
+	}
+
+	if idx := bytes.LastIndexByte(data, '\n'); idx >= 0 {
+		data = data[:idx+1]
+	}
+	return data, offset
+}
+
+// readLogLinesBefore returns the last n whole lines of a log that end at or before the virtual
+// offset (before < 0 means the end of the log) and the virtual offset of the first line.
+func readLogLinesBefore(path string, before int64, n int) ([]byte, int64) {
+	segments := LogSegments(path)
+	if len(segments) == 0 || n <= 0 {
+		return nil, 0
+	}
+	first := segments[0].Start
+	if end := segmentEnd(segments, len(segments)-1); before < 0 || before > end {
+		before = end
+	}
+	if before <= first {
+		return nil, first
+	}
+
+	// Read increasingly larger windows until enough lines are found
+	for window := int64(64 << 10); ; window *= 4 {
+		start := max(first, before-window)
+		data, dataStart := readLogLineRange(path, start, before-start)
+		lines := bytes.Count(data, []byte{'\n'})
+		if lines < n && start > first {
+			continue
+		}
+		for ; lines > n; lines-- {
+			idx := bytes.IndexByte(data, '\n')
+			data = data[idx+1:]
+			dataStart += int64(idx + 1)
+		}
+		return data, dataStart
+	}
+}
+
+// logBounds returns the virtual offsets of the first and past the last retained byte of a log
+func logBounds(path string) (int64, int64) {
+	segments := LogSegments(path)
+	if len(segments) == 0 {
+		return 0, 0
+	}
+	return segments[0].Start, segmentEnd(segments, len(segments)-1)
+}
+
+// readLogIndex loads the segment index of a log (empty if missing or invalid)
+func readLogIndex(path string) logIndex {
+	var index logIndex
+	data, err := os.ReadFile(path + ".index")
+	if err == nil {
+		json.Unmarshal(data, &index)
+	}
+	if index.Segments == nil {
+		index.Segments = make(map[string]int64)
+	}
+	return index
+}
+
+// writeLogIndex atomically replaces the segment index of a log
+func writeLogIndex(path string, index logIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	tempPath := path + ".index.tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, path+".index"); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// parseByteSize parses sizes like "512", "10KB", "100MB" or "1GB" (binary units)
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		value  int64
+	}{
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.value
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return value * multiplier, nil
+}
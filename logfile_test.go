@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFile_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	lf, err := OpenLogFile(path, &LogRotationConfig{MaxSize: "20B"})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+
+	lines := []string{"line one\n", "line two\n", "line three\n"}
+	for _, line := range lines {
+		lf.WriteString(line)
+		time.Sleep(2 * time.Millisecond) // Distinct segment timestamps
+	}
+	if got, want := lf.Offset(), int64(len(strings.Join(lines, ""))); got != want {
+		t.Errorf("Expected virtual offset %d, got %d", want, got)
+	}
+	lf.Close()
+
+	segments := LogSegments(path)
+	if len(segments) != 2 {
+		t.Fatalf("Expected 1 rotated segment and the active file, got %d", len(segments))
+	}
+	if segments[1].Path != path || segments[1].Start != int64(len(lines[0]+lines[1])) {
+		t.Errorf("Expected active file to start at %d, got %+v", len(lines[0]+lines[1]), segments[1])
+	}
+
+	if got := string(readLogTail(path, 1024)); got != strings.Join(lines, "") {
+		t.Errorf("Expected tail across segments %q, got %q", strings.Join(lines, ""), got)
+	}
+}
+
+func TestLogFile_KeepAndCompress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	lf, err := OpenLogFile(path, &LogRotationConfig{MaxSize: "10B", Keep: 2, Compress: true})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		lf.WriteString("0123456789\n")
+		time.Sleep(2 * time.Millisecond)
+	}
+	lf.Close()
+	waitLogCompression()
+
+	rotated, err := rotatedLogSegments(path)
+	if err != nil {
+		t.Fatalf("rotatedLogSegments failed: %v", err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated segments to be kept, got %v", rotated)
+	}
+	for _, segment := range rotated {
+		if !strings.HasSuffix(segment, ".gz") {
+			t.Errorf("Expected segment %s to be compressed", segment)
+		}
+	}
+
+	// Compressed segments are readable
+	segments := LogSegments(path)
+	reader, err := segments[0].Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "0123456789\n" {
+		t.Errorf("Expected decompressed segment content, got %q", data)
+	}
+
+	if got := string(readLogTail(path, 22)); got != "0123456789\n0123456789\n" {
+		t.Errorf("Unexpected tail %q", got)
+	}
+}
+
+func TestLogFile_DailyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	if err := os.WriteFile(path, []byte("yesterday\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(path, yesterday, yesterday); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+
+	lf, err := OpenLogFile(path, &LogRotationConfig{Daily: true})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	lf.WriteString("today\n")
+	lf.Close()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "today\n" {
+		t.Errorf("Expected active file to only contain today's output, got %q", data)
+	}
+	if rotated, _ := rotatedLogSegments(path); len(rotated) != 1 {
+		t.Errorf("Expected yesterday's log to be rotated, got %v", rotated)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+		err   bool
+	}{
+		{"512", 512, false},
+		{"10KB", 10 << 10, false},
+		{"100mb", 100 << 20, false},
+		{"1G", 1 << 30, false},
+		{"lots", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.input)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d (error: %v)", tt.input, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestLogFile_DatedPath(t *testing.T) {
+	dir := t.TempDir()
+	lf, err := OpenLogFile(filepath.Join(dir, "{date}", "svc.log"), nil)
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	lf.WriteString("hello\n")
+	lf.Close()
+
+	want := filepath.Join(dir, time.Now().Format("2006-01-02"), "svc.log")
+	if lf.Path() != want {
+		t.Errorf("Expected active path %s, got %s", want, lf.Path())
+	}
+	if data, err := os.ReadFile(want); err != nil || string(data) != "hello\n" {
+		t.Errorf("Expected dated log to contain output, got %q (%v)", data, err)
+	}
+}
+
+func TestReadLogRanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	lf, err := OpenLogFile(path, &LogRotationConfig{MaxSize: "20B", Compress: true})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	lines := []string{"line one\n", "line two\n", "line three\n", "line four\n", "line five\n"}
+	for _, line := range lines {
+		lf.WriteString(line)
+		time.Sleep(2 * time.Millisecond) // Distinct segment timestamps
+	}
+	lf.Close()
+	waitLogCompression()
+
+	all := strings.Join(lines, "")
+	if first, end := logBounds(path); first != 0 || end != int64(len(all)) {
+		t.Fatalf("Expected bounds 0-%d, got %d-%d", len(all), first, end)
+	}
+
+	// Offsets in the middle of a line start at the next line, across segment boundaries
+	data, start := readLogLineRange(path, 3, 30)
+	if start != int64(len(lines[0])) || string(data) != "line two\nline three\n" {
+		t.Errorf("Unexpected range at %d: %q", start, data)
+	}
+
+	// The last lines before an offset
+	before := int64(len(all) - len(lines[4]))
+	data, start = readLogLinesBefore(path, before, 2)
+	if want := int64(len(lines[0] + lines[1])); start != want || string(data) != "line three\nline four\n" {
+		t.Errorf("Expected lines three and four at %d, got %q at %d", want, data, start)
+	}
+
+	// More lines than exist returns everything
+	data, start = readLogLinesBefore(path, -1, 100)
+	if start != 0 || string(data) != all {
+		t.Errorf("Expected the whole log, got %q at %d", data, start)
+	}
+
+	parsed := parseLogLinesAt(data, "stdout", start)
+	if len(parsed) != len(lines) || parsed[2].Offset != int64(len(lines[0]+lines[1])) || parsed[2].Text != "line three" {
+		t.Errorf("Unexpected parsed lines %+v", parsed)
+	}
+}
+
+func TestLogFile_RotationsWithinOneMillisecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	lf, err := OpenLogFile(path, &LogRotationConfig{MaxSize: "5B"})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	var all string
+	for i := 0; i < 12; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		lf.WriteString(line)
+		all += line
+	}
+	lf.Close()
+
+	// No segment is overwritten and the segments stay in order
+	if rotated, _ := rotatedLogSegments(path); len(rotated) != 11 {
+		t.Errorf("Expected 11 rotated segments, got %d", len(rotated))
+	}
+	if got := string(readLogTail(path, 1024)); got != all {
+		t.Errorf("Expected all lines in order, got %q", got)
+	}
+}
+
+func TestLogFile_TruncateDuringCompression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	lf, err := OpenLogFile(path, &LogRotationConfig{MaxSize: "10B", Compress: true})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		lf.WriteString("0123456789\n")
+	}
+	if err := lf.Truncate(); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	lf.Close()
+	waitLogCompression()
+
+	// Compressions that were pending when the log was truncated leave nothing behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "svc-stdout.log.") && entry.Name() != "svc-stdout.log.index" {
+			t.Errorf("Expected no segments after truncating, found %s", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log file formats
+const (
+	LogFormatRaw      = "raw"      // Only the line text (default)
+	LogFormatPrefixed = "prefixed" // "<RFC3339 time> <stream> <pid> <text>"
+	LogFormatJSON     = "json"     // One JSON LogLine per line
+)
+
+const logLineTimeFormat = "2006-01-02T15:04:05.000Z07:00" // Timestamp format of prefixed log lines
+
+// LogLine is a single line of service output with its metadata
+type LogLine struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"` // "stdout" or "stderr"
+	RunID  string    `json:"run,omitempty"`
+	PID    int       `json:"pid,omitempty"`
+	Text   string    `json:"text"`             // Without the trailing newline
+	Offset int64     `json:"offset,omitempty"` // Virtual byte offset of the line in its log file (not stored in the file)
+
+	// Fields extracted from structured output (see log_format, not stored in the file)
+	Level   string    `json:"level,omitempty"` // Normalized level: trace, debug, info, warn, error or fatal
+	Message string    `json:"msg,omitempty"`
+	AppTime time.Time `json:"ts,omitzero"` // Time logged by the service itself
+
+	HTML string `json:"html,omitempty"` // Text with its colors as HTML spans (ansi: render, not stored in the file)
+
+	// Live streaming (not stored in the file)
+	Seq     int64 `json:"seq,omitempty"`     // Position in the stream, increasing by one per line
+	Dropped int   `json:"dropped,omitempty"` // Set on markers: number of lines a slow client missed
+}
+
+// isValidLogFormat returns true if the format is empty or a known log file format
+func isValidLogFormat(format string) bool {
+	return format == "" || format == LogFormatRaw || format == LogFormatPrefixed || format == LogFormatJSON
+}
+
+// formatLogLine renders a line for a log file in the given format (including the newline)
+func formatLogLine(line LogLine, format string) string {
+	switch format {
+	case LogFormatPrefixed:
+		return line.Time.Format(logLineTimeFormat) + " " + line.Stream + " " + strconv.Itoa(line.PID) + " " + line.Text + "\n"
+	case LogFormatJSON:
+		line.Offset, line.Level, line.Message, line.AppTime, line.HTML, line.Seq = 0, "", "", time.Time{}, "", 0
+		data, err := json.Marshal(line)
+		if err != nil {
+			return line.Text + "\n"
+		}
+		return string(data) + "\n"
+	default:
+		return line.Text + "\n"
+	}
+}
+
+// parseLogLine parses a line read from a log file. The format is detected per line, so files
+// that changed format over time can be read. Raw lines get the given stream and no timestamp.
+func parseLogLine(text, stream string) LogLine {
+	text = strings.TrimSuffix(text, "\n")
+	text = strings.TrimSuffix(text, "\r")
+
+	// JSON record
+	if strings.HasPrefix(text, `{"time":`) {
+		var line LogLine
+		// Services logging JSON themselves (e.g. slog) also start with "time"
+		if err := json.Unmarshal([]byte(text), &line); err == nil && (line.Stream == "stdout" || line.Stream == "stderr") {
+			return line
+		}
+	}
+
+	// Prefixed record: <time> <stream> <pid> <text>
+	if len(text) > len(logLineTimeFormat)-6 && text[0] >= '0' && text[0] <= '9' {
+		parts := strings.SplitN(text, " ", 4)
+		if len(parts) >= 3 && (parts[1] == "stdout" || parts[1] == "stderr") {
+			if t, err := time.Parse(logLineTimeFormat, parts[0]); err == nil {
+				if pid, err := strconv.Atoi(parts[2]); err == nil {
+					line := LogLine{Time: t, Stream: parts[1], PID: pid}
+					if len(parts) == 4 {
+						line.Text = parts[3]
+					}
+					return line
+				}
+			}
+		}
+	}
+
+	return LogLine{Stream: stream, Text: text}
+}
+
+// parseLogLines splits log data into lines. If partial is true the data may start in the
+// middle of a line, so the first (incomplete) line is dropped.
+func parseLogLines(data []byte, stream string, partial bool) []LogLine {
+	text := string(data)
+	if partial {
+		if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+			text = text[idx+1:]
+		} else {
+			return nil
+		}
+	}
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+
+	rawLines := strings.Split(text, "\n")
+	lines := make([]LogLine, 0, len(rawLines))
+	for _, raw := range rawLines {
+		lines = append(lines, parseLogLine(raw, stream))
+	}
+	return lines
+}
+
+// mergeLogLines interleaves two time-ordered line slices by timestamp. Ties keep a before b,
+// and lines without a timestamp cannot be compared, so a is preferred for them.
+func mergeLogLines(a, b []LogLine) []LogLine {
+	merged := make([]LogLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !b[j].Time.IsZero() && !a[i].Time.IsZero() && b[j].Time.Before(a[i].Time) {
+			merged = append(merged, b[j])
+			j++
+		} else {
+			merged = append(merged, a[i])
+			i++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
+
+// parseLogLinesAt splits whole lines of log data that starts at the virtual offset start
+// and assigns every line its offset.
+func parseLogLinesAt(data []byte, stream string, start int64) []LogLine {
+	var lines []LogLine
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		line := parseLogLine(string(data[:n]), stream)
+		line.Offset = start
+		lines = append(lines, line)
+		data = data[n:]
+		start += int64(n)
+	}
+	return lines
+}
+
+// inTimeRange reports whether the line lies within [since, until] (zero bounds are open).
+// Lines without a timestamp only match an unbounded range.
+func (l LogLine) inTimeRange(since, until time.Time) bool {
+	if since.IsZero() && until.IsZero() {
+		return true
+	}
+	if l.Time.IsZero() {
+		return false
+	}
+	if !since.IsZero() && l.Time.Before(since) {
+		return false
+	}
+	if !until.IsZero() && l.Time.After(until) {
+		return false
+	}
+	return true
+}
+
+// timeRangeFilter selects the lines of a log within [since, until] (zero bounds are open).
+// Lines without a timestamp (raw log files, continuation lines) take the time of the previous
+// timestamped line. Without one, they match if the time window of their segment (from the
+// end of the previous segment to the end of theirs) overlaps the range.
+type timeRangeFilter struct {
+	since, until time.Time
+	from, to     time.Time // Window of the current segment (zero = unknown)
+	last         time.Time // Time of the previous timestamped line
+}
+
+// segment starts filtering the lines of the next segment
+func (f *timeRangeFilter) segment(seg LogSegment, prevEnd time.Time) {
+	f.from, f.to = prevEnd, seg.End
+}
+
+// matches reports whether the next line of the segment lies within the range
+func (f *timeRangeFilter) matches(line LogLine) bool {
+	if f.since.IsZero() && f.until.IsZero() {
+		return true
+	}
+	if !line.Time.IsZero() {
+		f.last = line.Time
+		return line.inTimeRange(f.since, f.until)
+	}
+	if !f.last.IsZero() {
+		return LogLine{Time: f.last}.inTimeRange(f.since, f.until)
+	}
+	return (f.until.IsZero() || f.from.IsZero() || !f.from.After(f.until)) &&
+		(f.since.IsZero() || f.to.IsZero() || !f.to.Before(f.since))
+}
+
+// logLineWriter converts complete log file lines written to it into an output format:
+// "text" (only the line text) or "json" (one LogLine per line). Incomplete trailing
+// data is kept until the rest of the line arrives.
+type logLineWriter struct {
+	w       io.Writer
+	format  string
+	stream  string // Stream assigned to raw lines
+	partial []byte
+}
+
+// Write implements io.Writer
+func (lw *logLineWriter) Write(p []byte) (int, error) {
+	lw.partial = append(lw.partial, p...)
+	for {
+		idx := bytes.IndexByte(lw.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		line := parseLogLine(string(lw.partial[:idx]), lw.stream)
+		lw.partial = lw.partial[idx+1:]
+
+		var out []byte
+		if lw.format == "json" {
+			data, err := json.Marshal(line)
+			if err != nil {
+				return 0, err
+			}
+			out = append(data, '\n')
+		} else {
+			out = []byte(line.Text + "\n")
+		}
+		if _, err := lw.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
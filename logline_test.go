@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLine_FormatAndParseRoundTrip(t *testing.T) {
+	line := LogLine{
+		Time:   time.Date(2024, 3, 4, 5, 6, 7, 890000000, time.UTC),
+		Stream: "stderr",
+		RunID:  "20240304-050607.000",
+		PID:    4321,
+		Text:   "something failed: disk full",
+	}
+
+	for _, format := range []string{LogFormatPrefixed, LogFormatJSON} {
+		formatted := formatLogLine(line, format)
+		if !strings.HasSuffix(formatted, "\n") {
+			t.Errorf("%s: expected trailing newline, got %q", format, formatted)
+		}
+
+		parsed := parseLogLine(formatted, "stdout")
+		if !parsed.Time.Equal(line.Time) || parsed.Stream != line.Stream || parsed.PID != line.PID || parsed.Text != line.Text {
+			t.Errorf("%s: round trip mismatch: got %+v, want %+v", format, parsed, line)
+		}
+	}
+
+	// Raw lines keep their text and get the default stream
+	parsed := parseLogLine(formatLogLine(line, LogFormatRaw), "stdout")
+	if parsed.Text != line.Text || parsed.Stream != "stdout" || !parsed.Time.IsZero() {
+		t.Errorf("raw: unexpected parse result %+v", parsed)
+	}
+}
+
+func TestParseLogLines_DropsPartialFirstLine(t *testing.T) {
+	lines := parseLogLines([]byte("tial line\nfirst\nsecond\n"), "stdout", true)
+	if len(lines) != 2 || lines[0].Text != "first" || lines[1].Text != "second" {
+		t.Errorf("Expected two complete lines, got %+v", lines)
+	}
+}
+
+func TestLogLine_InTimeRange(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	line := LogLine{Time: base, Text: "x"}
+
+	if !line.inTimeRange(time.Time{}, time.Time{}) {
+		t.Error("Expected unbounded range to match")
+	}
+	if !line.inTimeRange(base.Add(-time.Minute), base.Add(time.Minute)) {
+		t.Error("Expected line inside range to match")
+	}
+	if line.inTimeRange(base.Add(time.Minute), time.Time{}) {
+		t.Error("Expected line before since to be excluded")
+	}
+	if (LogLine{Text: "no time"}).inTimeRange(base, time.Time{}) {
+		t.Error("Expected line without timestamp to be excluded from a bounded range")
+	}
+}
+
+func TestCircularBuffer_EvictsOldestLines(t *testing.T) {
+	buf := NewCircularBuffer(12)
+	for _, text := range []string{"one", "two", "three", "four"} {
+		buf.Write(LogLine{Text: text})
+	}
+
+	lines := buf.Lines()
+	var texts []string
+	for _, line := range lines {
+		texts = append(texts, line.Text)
+	}
+	// "three\n" + "four\n" = 11 bytes; adding "two\n" would exceed 12
+	if strings.Join(texts, ",") != "three,four" {
+		t.Errorf("Expected newest lines within the size limit, got %v", texts)
+	}
+}
+
+func TestCircularBuffer_ManyWrites(t *testing.T) {
+	buf := NewCircularBuffer(100 * 5) // 100 lines of "NNNN\n"
+	for i := range 10000 {
+		buf.Write(LogLine{Text: fmt.Sprintf("%04d", i)})
+	}
+
+	lines := buf.Lines()
+	if len(lines) != 100 || lines[0].Text != "9900" || lines[99].Text != "9999" {
+		t.Fatalf("Expected the newest 100 lines, got %d lines from %q", len(lines), lines[0].Text)
+	}
+	// Dropped lines are compacted away, so the slice doesn't grow with the number of writes
+	if len(buf.lines) > 2*len(lines) {
+		t.Errorf("Expected at most %d lines in the slice, got %d", 2*len(lines), len(buf.lines))
+	}
+	if after, _ := buf.LinesAfter(lines[97].Seq); len(after) != 2 || after[0].Text != "9998" {
+		t.Errorf("Expected the last two lines, got %v", after)
+	}
+}
+
+func TestCircularBuffer_TruncatesOversizedLine(t *testing.T) {
+	buf := NewCircularBuffer(12)
+	buf.Write(LogLine{Text: "\x1b[31mred\x1b[0m and more"})
+	buf.Write(LogLine{Text: "ab€€€€"})
+
+	lines := buf.Lines()
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the newest line, got %d", len(lines))
+	}
+	// 11 bytes would end inside the fourth €, which is dropped whole
+	if lines[0].Text != "ab€€€ [truncated 3 bytes]" {
+		t.Errorf("Unexpected truncated text %q", lines[0].Text)
+	}
+}
+
+func TestCircularBuffer_LinesAfter(t *testing.T) {
+	buf := NewCircularBuffer(12)
+	last := buf.Write(LogLine{Text: "one"})
+	for _, text := range []string{"two", "three", "four"} {
+		seq := buf.Write(LogLine{Text: text})
+		if seq != last+1 {
+			t.Fatalf("Expected sequence %d, got %d", last+1, seq)
+		}
+		last = seq
+	}
+
+	// Only "three" and "four" are still buffered
+	lines, missed := buf.LinesAfter(last - 3)
+	if len(lines) != 2 || lines[0].Text != "three" || lines[0].Seq != last-1 || missed != 1 {
+		t.Errorf("Expected two lines and one missed, got %d lines and %d missed", len(lines), missed)
+	}
+	if lines, missed := buf.LinesAfter(last); len(lines) != 0 || missed != 0 {
+		t.Errorf("Expected nothing after the last line, got %d lines and %d missed", len(lines), missed)
+	}
+
+	// A sequence number from an earlier buffer returns everything without a gap
+	if lines, missed := buf.LinesAfter(1); len(lines) != 2 || missed != 0 {
+		t.Errorf("Expected all lines for an unknown sequence, got %d lines and %d missed", len(lines), missed)
+	}
+
+	// Cleared lines are not reported as missed
+	buf.Clear("")
+	buf.Write(LogLine{Text: "five"})
+	if lines, missed := buf.LinesAfter(last - 3); len(lines) != 1 || missed != 0 {
+		t.Errorf("Expected the new line after clearing, got %d lines and %d missed", len(lines), missed)
+	}
+}
+
+func TestBroadcaster_DroppedMarker(t *testing.T) {
+	b := NewBroadcaster()
+	ch := b.Subscribe()
+	defer b.Unsubscribe(ch)
+
+	total := cap(ch) + 5
+	for i := range total {
+		b.Broadcast(LogLine{Text: fmt.Sprint(i)})
+	}
+	for range cap(ch) {
+		<-ch
+	}
+	b.Broadcast(LogLine{Text: "next"})
+
+	marker := <-ch
+	if marker.Dropped != 5 || marker.Text != "[service-manager] 5 lines dropped" {
+		t.Errorf("Expected marker for 5 dropped lines, got %+v", marker)
+	}
+	if line := <-ch; line.Text != "next" {
+		t.Errorf("Expected the new line after the marker, got %q", line.Text)
+	}
+}
+
+func TestLogLineWriter_ConvertsCompleteLines(t *testing.T) {
+	var out bytes.Buffer
+	lw := &logLineWriter{w: &out, format: "text", stream: "stdout"}
+
+	prefixed := formatLogLine(LogLine{Time: time.Now(), Stream: "stdout", PID: 1, Text: "hello"}, LogFormatPrefixed)
+	lw.Write([]byte(prefixed[:10]))
+	if out.Len() != 0 {
+		t.Fatalf("Expected incomplete line to be held back, got %q", out.String())
+	}
+	lw.Write([]byte(prefixed[10:]))
+	if out.String() != "hello\n" {
+		t.Errorf("Expected converted text line, got %q", out.String())
+	}
+}
+
+func TestMergeLogLines(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stdout := []LogLine{
+		{Time: base, Stream: "stdout", Text: "a"},
+		{Time: base.Add(2 * time.Second), Stream: "stdout", Text: "c"},
+	}
+	stderr := []LogLine{
+		{Time: base.Add(time.Second), Stream: "stderr", Text: "b"},
+		{Time: base.Add(2 * time.Second), Stream: "stderr", Text: "d"},
+	}
+
+	var got []string
+	for _, line := range mergeLogLines(stdout, stderr) {
+		got = append(got, line.Text)
+	}
+	if strings.Join(got, "") != "abcd" {
+		t.Errorf("Expected lines interleaved by time, got %v", got)
+	}
+}
+
+func TestParseLogLine_ServiceJSONInRawFile(t *testing.T) {
+	// slog's JSON handler also starts lines with "time", but they are not LogLine records
+	text := `{"time":"2024-05-01T12:00:00Z","level":"INFO","msg":"hello"}`
+	line := parseLogLine(text, "stderr")
+	if line.Text != text || line.Stream != "stderr" {
+		t.Errorf("Expected service JSON to be kept as raw text, got %+v", line)
+	}
+}
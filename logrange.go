@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultRangeLines = 100      // Lines returned by a tail read without a line count
+	maxRangeLines     = 10000    // Upper bound for tail reads
+	defaultRangeBytes = 64 << 10 // Bytes returned by an offset read without a limit
+	maxRangeBytes     = 1 << 20  // Upper bound for offset reads
+)
+
+// LogRange is a slice of whole lines of a log file. All offsets are virtual, so they stay
+// valid after rotation and can be passed back to read the following or preceding lines.
+type LogRange struct {
+	Lines    []LogLine `json:"lines"`
+	Start    int64     `json:"start"`    // Offset of the first returned line
+	End      int64     `json:"end"`      // Offset just past the last returned line
+	LogStart int64     `json:"logStart"` // Offset of the oldest retained byte of the log
+	LogEnd   int64     `json:"logEnd"`   // Offset of the end of the log
+}
+
+// logPathForStream returns the current log file of a stream. With log: combined all streams
+// share one file; the combined stream needs a combined file (log: combined or both).
+func (s *Service) logPathForStream(stream string) (string, error) {
+	switch {
+	case s.Config.IsCombinedLog():
+		return s.stdoutLogPath(), nil
+	case stream == "stdout":
+		return s.stdoutLogPath(), nil
+	case stream == "stderr":
+		return s.stderrLogPath(), nil
+	case stream == "combined" && s.Config.HasCombinedLogFile():
+		return expandLogDate(s.combinedLogTemplate(), time.Now()), nil
+	}
+	return "", fmt.Errorf("service '%s' has no %s log file", s.Config.Name, stream)
+}
+
+// ReadLogRange reads whole lines of a stream's log starting at the first line at or after offset
+func (s *Service) ReadLogRange(stream string, offset, limit int64) (LogRange, error) {
+	path, err := s.logPathForStream(stream)
+	if err != nil {
+		return LogRange{}, err
+	}
+	data, start := readLogLineRange(path, offset, limit)
+	return s.newLogRange(path, stream, data, start), nil
+}
+
+// ReadLogTail reads the last n lines of a stream's log that end at or before the offset
+// (before < 0 means the end of the log)
+func (s *Service) ReadLogTail(stream string, before int64, n int) (LogRange, error) {
+	path, err := s.logPathForStream(stream)
+	if err != nil {
+		return LogRange{}, err
+	}
+	data, start := readLogLinesBefore(path, before, n)
+	return s.newLogRange(path, stream, data, start), nil
+}
+
+// newLogRange parses the lines read from a log into a LogRange
+func (s *Service) newLogRange(path, stream string, data []byte, start int64) LogRange {
+	logStart, logEnd := logBounds(path)
+
+	// Raw lines of a combined file cannot be told apart and count as stdout
+	rawStream := stream
+	if rawStream == "combined" {
+		rawStream = "stdout"
+	}
+	lines := s.annotateLines(parseLogLinesAt(data, rawStream, start))
+
+	// A combined file holds both streams, only return the requested one
+	if stream != "combined" && s.Config.IsCombinedLog() {
+		filtered := lines[:0]
+		for _, line := range lines {
+			if line.Stream == stream {
+				filtered = append(filtered, line)
+			}
+		}
+		lines = filtered
+	}
+
+	if lines == nil {
+		lines = []LogLine{}
+	}
+	return LogRange{
+		Lines:    lines,
+		Start:    start,
+		End:      start + int64(len(data)),
+		LogStart: logStart,
+		LogEnd:   logEnd,
+	}
+}
+
+// TailLogLines returns the last n lines of a stream. The buffered lines are used when they
+// suffice, otherwise the lines before the buffer are read from the log files. Buffered lines
+// keep their sequence numbers, so a stream continuing after them skips no line and sends
+// none twice.
+func (s *Service) TailLogLines(stream string, buffered []LogLine, n int) []LogLine {
+	if n <= len(buffered) {
+		return buffered[len(buffered)-n:]
+	}
+	more := n - len(buffered)
+
+	// The file tail ends where the buffer of the stream begins (-1 = no buffered line, the
+	// whole file precedes the buffer)
+	before := func(stream string) int64 {
+		for _, line := range buffered {
+			if stream == "" || line.Stream == stream {
+				return line.Offset
+			}
+		}
+		return -1
+	}
+
+	if logRange, err := s.ReadLogTail(stream, before(""), more); err == nil {
+		return append(logRange.Lines, buffered...)
+	}
+
+	// Without a combined file, interleave the tails of both streams
+	stdout, err := s.ReadLogTail("stdout", before("stdout"), more)
+	if err != nil {
+		return buffered
+	}
+	stderr, err := s.ReadLogTail("stderr", before("stderr"), more)
+	if err != nil {
+		return buffered
+	}
+	lines := mergeLogLines(stdout.Lines, stderr.Lines)
+	return append(lines[max(len(lines)-more, 0):], buffered...)
+}
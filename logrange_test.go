@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestService_TailLogLines(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web"}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	var output strings.Builder
+	for i := 0; i < 2000; i++ {
+		output.WriteString("some output line\n")
+	}
+	svc.readLogs(strings.NewReader(output.String()), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.readLogs(strings.NewReader("oops\n"), LogLine{Stream: "stderr"}, svc.stderrFile, nil, svc.stderrBuf, svc.stderrBroadcast)
+	svc.closeLogFiles()
+
+	buffered := svc.GetStdoutBuffer()
+	if len(buffered) >= 1500 {
+		t.Fatalf("Expected the buffer to hold fewer lines than written, got %d", len(buffered))
+	}
+
+	// More lines than buffered are read from the file
+	lines := svc.TailLogLines("stdout", buffered, 1500)
+	if len(lines) != 1500 {
+		t.Fatalf("Expected 1500 lines, got %d", len(lines))
+	}
+	if want := int64(500 * len("some output line\n")); lines[0].Offset != want {
+		t.Errorf("Expected first line at offset %d, got %d", want, lines[0].Offset)
+	}
+	if last := buffered[len(buffered)-1]; lines[1499].Offset != last.Offset {
+		t.Errorf("Expected file and buffer offsets to match, got %d and %d", lines[1499].Offset, last.Offset)
+	}
+	// The file tail ends where the buffer begins; the buffered lines keep their sequence numbers
+	fromFile := len(lines) - len(buffered)
+	if lines[fromFile].Seq != buffered[0].Seq || lines[fromFile-1].Seq != 0 {
+		t.Errorf("Expected the buffered lines after the file lines, got seq %d after %d", lines[fromFile].Seq, lines[fromFile-1].Seq)
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i].Offset <= lines[i-1].Offset {
+			t.Fatalf("Expected every line once, got offset %d after %d", lines[i].Offset, lines[i-1].Offset)
+		}
+	}
+
+	// Without a combined file both streams are read
+	if combined := svc.TailLogLines("combined", nil, 3); len(combined) != 3 {
+		t.Errorf("Expected 3 combined lines, got %+v", combined)
+	}
+	combinedBuffer := svc.GetCombinedBuffer()
+	if combined := svc.TailLogLines("combined", combinedBuffer, len(combinedBuffer)+2); len(combined) != len(combinedBuffer)+2 ||
+		combined[2].Seq != combinedBuffer[0].Seq || combined[1].Seq != 0 {
+		t.Errorf("Expected two file lines before the combined buffer, got %d lines", len(combined))
+	}
+
+	// Older lines page backwards from an offset
+	older, err := svc.ReadLogTail("stdout", lines[0].Offset, 10)
+	if err != nil || len(older.Lines) != 10 || older.End != lines[0].Offset {
+		t.Errorf("Expected 10 lines before offset %d, got %+v (%v)", lines[0].Offset, older, err)
+	}
+}
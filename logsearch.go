@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 100  // Matches returned when no limit is given
+	maxSearchLimit     = 1000 // Upper bound for the limit parameter
+	maxSearchContext   = 20   // Upper bound for the context parameter
+)
+
+// LogSearchQuery describes a search through the on-disk logs of a service
+type LogSearchQuery struct {
+	Pattern  *regexp.Regexp // Matched against the line text (plain queries are quoted)
+	Stream   string         // "stdout", "stderr" or "" for both
+	Since    time.Time      // Zero for no lower bound
+	Until    time.Time      // Zero for no upper bound
+	Context  int            // Lines of context before and after each match
+	Limit    int            // Maximum number of matches
+	MinLevel int            // Minimum level rank of matches (0 = any, see logLevelRank)
+
+	parser *logFieldParser // Extracts the level of lines (set by Service.SearchLogs)
+}
+
+// LogMatch is a line found by a log search
+type LogMatch struct {
+	LogLine
+	File   string   `json:"file"` // Log the line was found in (active path, not the rotated segment)
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// LogSearchResult is the response of a log search
+type LogSearchResult struct {
+	Matches   []LogMatch `json:"matches"`
+	Truncated bool       `json:"truncated"` // More matches exist beyond the limit
+}
+
+// searchLog scans all segments of a log (oldest first) and appends matches to result.
+// stream is assigned to raw lines; lines of other streams than query.Stream are skipped.
+// It returns false once the limit is reached.
+func searchLog(path, stream string, query LogSearchQuery, result *LogSearchResult) bool {
+	var before []string // Ring of preceding lines for context
+	var pending []int   // Matches still collecting lines of context after them
+	filter := timeRangeFilter{since: query.Since, until: query.Until}
+
+	var prevEnd time.Time
+	for _, seg := range LogSegments(path) {
+		filter.segment(seg, prevEnd)
+		prevEnd = seg.End
+		reader, err := seg.Open()
+		if err != nil {
+			continue
+		}
+
+		br := bufio.NewReader(reader)
+		offset := seg.Start
+		for {
+			raw, err := br.ReadString('\n')
+			if raw == "" {
+				break
+			}
+			lineOffset := offset
+			offset += int64(len(raw))
+
+			line := parseLogLine(raw, stream)
+			line.Offset = lineOffset
+			query.parser.annotate(&line)
+			if query.Stream != "" && line.Stream != query.Stream {
+				continue
+			}
+
+			// Fill the context of previous matches
+			for i := 0; i < len(pending); {
+				match := &result.Matches[pending[i]]
+				match.After = append(match.After, line.Text)
+				if len(match.After) >= query.Context {
+					pending = append(pending[:i], pending[i+1:]...)
+				} else {
+					i++
+				}
+			}
+
+			if filter.matches(line) && line.hasMinLevel(query.MinLevel) && query.Pattern.MatchString(line.Text) {
+				if len(result.Matches) >= query.Limit {
+					result.Truncated = true
+				} else {
+					result.Matches = append(result.Matches, LogMatch{
+						LogLine: line,
+						File:    path,
+						Before:  append([]string(nil), before...),
+					})
+					if query.Context > 0 {
+						pending = append(pending, len(result.Matches)-1)
+					}
+				}
+			}
+
+			// Stop once the limit is hit and all context is collected
+			if result.Truncated && len(pending) == 0 {
+				reader.Close()
+				return false
+			}
+
+			if query.Context > 0 {
+				before = append(before, line.Text)
+				if len(before) > query.Context {
+					before = before[1:]
+				}
+			}
+
+			if err == io.EOF {
+				break
+			}
+		}
+		reader.Close()
+	}
+
+	return !result.Truncated
+}
+
+// SearchLogs searches the service's log files, including rotated segments and, for logs with a
+// {date} path template, the files of all dates.
+func (s *Service) SearchLogs(query LogSearchQuery) LogSearchResult {
+	result := LogSearchResult{Matches: []LogMatch{}}
+	query.parser = s.fieldParser
+
+	search := func(template, stream string) bool {
+		for _, path := range datedLogPaths(template) {
+			if !searchLog(path, stream, query, &result) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// A combined file has both streams, lines are filtered by their parsed stream
+	if s.Config.IsCombinedLog() {
+		search(s.stdoutLogTemplate(), "stdout")
+		return result
+	}
+
+	if query.Stream != "stderr" && !search(s.stdoutLogTemplate(), "stdout") {
+		return result
+	}
+	if query.Stream != "stdout" {
+		search(s.stderrLogTemplate(), "stderr")
+	}
+	return result
+}
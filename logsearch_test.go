@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchLog_AcrossSegmentsWithContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-stdout.log")
+	lf, err := OpenLogFile(path, &LogRotationConfig{MaxSize: "30B", Compress: true})
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	lines := []string{"boot\n", "error: first\n", "working\n", "still working\n", "error: second\n", "done\n"}
+	for _, line := range lines {
+		lf.WriteString(line)
+		time.Sleep(2 * time.Millisecond) // Distinct segment timestamps
+	}
+	lf.Close()
+	waitLogCompression()
+
+	if len(LogSegments(path)) < 2 {
+		t.Fatalf("Expected the log to be rotated")
+	}
+
+	query := LogSearchQuery{Pattern: regexp.MustCompile(`^error:`), Context: 1, Limit: 10}
+	var result LogSearchResult
+	searchLog(path, "stdout", query, &result)
+
+	if len(result.Matches) != 2 || result.Truncated {
+		t.Fatalf("Expected 2 matches, got %+v", result)
+	}
+	second := result.Matches[1]
+	if second.Text != "error: second" || second.Stream != "stdout" {
+		t.Errorf("Unexpected second match %+v", second)
+	}
+	if want := int64(len(lines[0] + lines[1] + lines[2] + lines[3])); second.Offset != want {
+		t.Errorf("Expected virtual offset %d, got %d", want, second.Offset)
+	}
+	if len(second.Before) != 1 || second.Before[0] != "still working" || len(second.After) != 1 || second.After[0] != "done" {
+		t.Errorf("Unexpected context before=%v after=%v", second.Before, second.After)
+	}
+
+	// The limit truncates the result
+	query.Limit = 1
+	result = LogSearchResult{}
+	searchLog(path, "stdout", query, &result)
+	if len(result.Matches) != 1 || !result.Truncated {
+		t.Errorf("Expected 1 match and truncation, got %+v", result)
+	}
+	if len(result.Matches) == 1 && len(result.Matches[0].After) != 1 {
+		t.Errorf("Expected context of the last match to be complete, got %v", result.Matches[0].After)
+	}
+}
+
+func TestService_SearchLogsStreamAndTime(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web", LogFileFormat: LogFormatPrefixed}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	svc.readLogs(strings.NewReader("request 1\n"), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.readLogs(strings.NewReader("request 2 failed\n"), LogLine{Stream: "stderr"}, svc.stderrFile, nil, svc.stderrBuf, svc.stderrBroadcast)
+	svc.closeLogFiles()
+
+	pattern := regexp.MustCompile(`request`)
+	if got := svc.SearchLogs(LogSearchQuery{Pattern: pattern, Limit: 10}); len(got.Matches) != 2 {
+		t.Errorf("Expected matches in both streams, got %+v", got.Matches)
+	}
+	if got := svc.SearchLogs(LogSearchQuery{Pattern: pattern, Stream: "stderr", Limit: 10}); len(got.Matches) != 1 || got.Matches[0].Stream != "stderr" {
+		t.Errorf("Expected only the stderr match, got %+v", got.Matches)
+	}
+	future := time.Now().Add(time.Hour)
+	if got := svc.SearchLogs(LogSearchQuery{Pattern: pattern, Since: future, Limit: 10}); len(got.Matches) != 0 {
+		t.Errorf("Expected no matches after %v, got %+v", future, got.Matches)
+	}
+}
+
+func TestService_SearchLogsRawTimeRangeAndDates(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web", StdoutPath: "{name}-{date}.log"}, GlobalConfig{})
+	if err := os.MkdirAll("logs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	yesterday := time.Now().AddDate(0, 0, -1)
+	older := filepath.Join("logs", "web-"+yesterday.Format("2006-01-02")+".log")
+	if err := os.WriteFile(older, []byte("request 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(older, yesterday, yesterday)
+
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	svc.readLogs(strings.NewReader("request 1\n"), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.closeLogFiles()
+
+	// The files of all dates are searched
+	pattern := regexp.MustCompile(`request`)
+	got := svc.SearchLogs(LogSearchQuery{Pattern: pattern, Stream: "stdout", Limit: 10})
+	if len(got.Matches) != 2 || got.Matches[0].Text != "request 0" || got.Matches[1].Text != "request 1" {
+		t.Errorf("Expected the matches of both dates oldest first, got %+v", got.Matches)
+	}
+
+	// Raw lines have no timestamp, the modification times of the files decide
+	got = svc.SearchLogs(LogSearchQuery{Pattern: pattern, Stream: "stdout", Since: time.Now().Add(-time.Hour), Limit: 10})
+	if len(got.Matches) != 1 || got.Matches[0].Text != "request 1" {
+		t.Errorf("Expected only today's match, got %+v", got.Matches)
+	}
+	if got := svc.SearchLogs(LogSearchQuery{Pattern: pattern, Stream: "stdout", Since: time.Now().Add(time.Hour), Limit: 10}); len(got.Matches) != 0 {
+		t.Errorf("Expected no matches in the future, got %+v", got.Matches)
+	}
+}
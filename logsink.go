@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogSink forwards the output lines of services to an external system. Send must not block:
+// sinks queue lines and deliver them in the background.
+type LogSink interface {
+	Send(service string, line LogLine)
+	Close() error
+}
+
+const (
+	defaultSinkBatchSize     = 100
+	defaultSinkFlushInterval = time.Second
+	defaultSinkBufferSize    = 10000
+	maxSinkRetryDelay        = 30 * time.Second
+	sinkCloseTimeout         = 5 * time.Second
+	defaultSyslogAddress     = "/dev/log"
+)
+
+// key identifies a sink configuration, so services with the same configuration share a sink
+func (c LogSinkConfig) key() string {
+	return fmt.Sprintf("%+v", c) // fmt prints maps sorted by key
+}
+
+// NewLogSink creates a sink from its configuration
+func NewLogSink(cfg LogSinkConfig) (LogSink, error) {
+	switch cfg.Type {
+	case LogSinkSyslog:
+		return newSyslogSink(cfg)
+	case LogSinkHTTP:
+		return newHTTPSink(cfg)
+	}
+	return nil, fmt.Errorf("unknown log sink type %q (must be %s or %s)", cfg.Type, LogSinkSyslog, LogSinkHTTP)
+}
+
+// ============================================================================
+// Queueing and delivery
+// ============================================================================
+
+// sinkEntry is a queued line with the name of the service that printed it
+type sinkEntry struct {
+	service string
+	line    LogLine
+}
+
+// sinkQueue is a bounded FIFO of entries. When it is full the oldest entries are dropped.
+type sinkQueue struct {
+	mu      sync.Mutex
+	entries []sinkEntry
+	max     int
+	dropped int           // Entries dropped since the last pop
+	notify  chan struct{} // Signaled when entries are added
+}
+
+func newSinkQueue(max int) *sinkQueue {
+	if max <= 0 {
+		max = defaultSinkBufferSize
+	}
+	return &sinkQueue{max: max, notify: make(chan struct{}, 1)}
+}
+
+// push appends an entry, dropping the oldest one if the queue is full
+func (q *sinkQueue) push(entry sinkEntry) {
+	q.mu.Lock()
+	if len(q.entries) >= q.max {
+		q.entries = q.entries[1:]
+		q.dropped++
+	}
+	q.entries = append(q.entries, entry)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes up to n of the oldest entries. It also returns the number of entries dropped
+// since the last call.
+func (q *sinkQueue) pop(n int) ([]sinkEntry, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n = min(n, len(q.entries))
+	batch := make([]sinkEntry, n)
+	copy(batch, q.entries[:n])
+	q.entries = q.entries[n:]
+
+	dropped := q.dropped
+	q.dropped = 0
+	return batch, dropped
+}
+
+// len returns the number of queued entries
+func (q *sinkQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// permanentError marks a delivery failure that retrying cannot fix (e.g. HTTP 400)
+type permanentError struct {
+	error
+}
+
+// batchSink implements the delivery loop shared by all sinks: queued lines are delivered in
+// batches once a batch is full or the flush interval passed, and failed batches are retried
+// with exponential backoff while new lines keep queueing.
+type batchSink struct {
+	name          string // Used in error messages
+	queue         *sinkQueue
+	batchSize     int
+	flushInterval time.Duration
+	deliver       func([]sinkEntry) error
+	finish        func() // Optional, called by the delivery loop when it exits
+	stop          chan struct{}
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+func newBatchSink(name string, cfg LogSinkConfig, batchSize int, deliver func([]sinkEntry) error) *batchSink {
+	flushInterval := defaultSinkFlushInterval
+	if cfg.FlushInterval != "" {
+		if d, err := time.ParseDuration(cfg.FlushInterval); err == nil && d > 0 {
+			flushInterval = d
+		}
+	}
+	return &batchSink{
+		name:          name,
+		queue:         newSinkQueue(cfg.BufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		deliver:       deliver,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Send queues a line for delivery
+func (b *batchSink) Send(service string, line LogLine) {
+	b.queue.push(sinkEntry{service: service, line: line})
+}
+
+// Close delivers the queued lines (one attempt) and stops the sink
+func (b *batchSink) Close() error {
+	b.closeOnce.Do(func() { close(b.stop) })
+	select {
+	case <-b.done:
+		return nil
+	case <-time.After(sinkCloseTimeout):
+		return fmt.Errorf("timed out flushing log sink %s", b.name)
+	}
+}
+
+// run is the delivery loop
+func (b *batchSink) run() {
+	defer close(b.done)
+	if b.finish != nil {
+		defer b.finish()
+	}
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			b.drain(false)
+			return
+		case <-ticker.C:
+			b.drain(true)
+		case <-b.queue.notify:
+			if b.queue.len() >= b.batchSize {
+				b.drain(true)
+			}
+		}
+	}
+}
+
+// drain delivers all queued entries. With retry, a failed batch is retried until it is
+// delivered or the sink is closed; without, it is dropped.
+func (b *batchSink) drain(retry bool) {
+	for {
+		batch, dropped := b.queue.pop(b.batchSize)
+		if dropped > 0 {
+			fmt.Fprintf(os.Stderr, "[LogSink] %s: dropped %d lines (buffer full)\n", b.name, dropped)
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		delay := time.Second
+		for {
+			err := b.deliver(batch)
+			if err == nil {
+				break
+			}
+
+			var permanent permanentError
+			if errors.As(err, &permanent) || !retry {
+				fmt.Fprintf(os.Stderr, "[LogSink] %s: dropped %d lines: %v\n", b.name, len(batch), err)
+				break
+			}
+
+			fmt.Fprintf(os.Stderr, "[LogSink] %s: %v (retrying in %v)\n", b.name, err, delay)
+			select {
+			case <-b.stop:
+				fmt.Fprintf(os.Stderr, "[LogSink] %s: dropped %d lines on shutdown\n", b.name, len(batch))
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxSinkRetryDelay)
+		}
+	}
+}
+
+// ============================================================================
+// Syslog (RFC 5424)
+// ============================================================================
+
+// syslogFacilities maps facility names to their RFC 5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSink sends every line as an RFC 5424 message over a unix socket or UDP
+type syslogSink struct {
+	*batchSink
+	network  string // unixgram or udp
+	address  string
+	facility int
+	hostname string
+	conn     net.Conn // Only used by the delivery loop
+	stream   bool     // conn is a unix stream socket (messages are newline-terminated)
+}
+
+func newSyslogSink(cfg LogSinkConfig) (*syslogSink, error) {
+	network, address, err := parseSyslogAddress(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	facility := syslogFacilities["user"]
+	if cfg.Facility != "" {
+		code, ok := syslogFacilities[strings.ToLower(cfg.Facility)]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", cfg.Facility)
+		}
+		facility = code
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &syslogSink{network: network, address: address, facility: facility, hostname: hostname}
+	// Lines are sent as soon as they arrive
+	s.batchSink = newBatchSink("syslog "+address, cfg, 1, s.deliver)
+	s.finish = s.closeConn
+	go s.run()
+	return s, nil
+}
+
+// parseSyslogAddress parses "", "/dev/log", "unix:///dev/log" or "udp://host:514"
+func parseSyslogAddress(address string) (network, addr string, err error) {
+	switch {
+	case address == "":
+		return "unixgram", defaultSyslogAddress, nil
+	case strings.HasPrefix(address, "udp://"):
+		return "udp", strings.TrimPrefix(address, "udp://"), nil
+	case strings.HasPrefix(address, "unix://"):
+		return "unixgram", strings.TrimPrefix(address, "unix://"), nil
+	case strings.HasPrefix(address, "/"):
+		return "unixgram", address, nil
+	}
+	return "", "", fmt.Errorf("invalid syslog address %q (expected a socket path, unix:///path or udp://host:port)", address)
+}
+
+// deliver writes the messages, reconnecting if the previous connection failed
+func (s *syslogSink) deliver(batch []sinkEntry) error {
+	if s.conn == nil {
+		conn, err := net.Dial(s.network, s.address)
+		if err != nil && s.network == "unixgram" {
+			// Some systems use a stream socket for /dev/log
+			conn, err = net.Dial("unix", s.address)
+			s.stream = err == nil
+		}
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	for _, entry := range batch {
+		msg := formatSyslogMessage(s.facility, s.hostname, entry.service, entry.line)
+		if s.stream {
+			msg += "\n"
+		}
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// closeConn closes the connection once the delivery loop exits (the loop owns the connection,
+// so Close only signals and waits)
+func (s *syslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// syslogSeverity maps the level of a line to an RFC 5424 severity (lines without level are informational)
+func syslogSeverity(line LogLine) int {
+	switch line.Level {
+	case "fatal":
+		return 2 // Critical
+	case "error":
+		return 3
+	case "warn":
+		return 4
+	case "debug", "trace":
+		return 7
+	}
+	return 6 // Informational
+}
+
+// formatSyslogMessage formats a line as an RFC 5424 message: the service name is the
+// APP-NAME, the PID the PROCID and the stream (stdout/stderr) the MSGID
+func formatSyslogMessage(facility int, hostname, service string, line LogLine) string {
+	procID := "-"
+	if line.PID > 0 {
+		procID = strconv.Itoa(line.PID)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s - %s",
+		facility*8+syslogSeverity(line),
+		line.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(hostname, 255),
+		syslogHeaderField(service, 48),
+		procID,
+		syslogHeaderField(line.Stream, 32),
+		line.Text)
+}
+
+// syslogHeaderField makes a value valid for an RFC 5424 header field (printable ASCII, no spaces)
+func syslogHeaderField(value string, maxLen int) string {
+	field := []byte(value)
+	for i, c := range field {
+		if c <= ' ' || c > '~' {
+			field[i] = '_'
+		}
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
+
+// ============================================================================
+// HTTP
+// ============================================================================
+
+// HTTP sink formats
+const (
+	httpSinkJSON    = "json"    // JSON array of line objects
+	httpSinkLoki    = "loki"    // Loki push API (/loki/api/v1/push)
+	httpSinkElastic = "elastic" // Elasticsearch bulk API (/_bulk or /{index}/_bulk)
+)
+
+// sinkDocument is a line as sent by the json HTTP format
+type sinkDocument struct {
+	Service string `json:"service"`
+	LogLine
+}
+
+// elasticDocument is a line as sent by the elastic HTTP format
+type elasticDocument struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Message   string    `json:"message"`
+	Service   string    `json:"service"`
+	Stream    string    `json:"stream"`
+	RunID     string    `json:"run,omitempty"`
+	PID       int       `json:"pid,omitempty"`
+	Level     string    `json:"level,omitempty"`
+}
+
+// httpSink pushes batches of lines to an HTTP endpoint
+type httpSink struct {
+	*batchSink
+	url     string
+	format  string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPSink(cfg LogSinkConfig) (*httpSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("http log sink needs a url")
+	}
+	format := cfg.Format
+	if format == "" {
+		format = httpSinkJSON
+	}
+	if format != httpSinkJSON && format != httpSinkLoki && format != httpSinkElastic {
+		return nil, fmt.Errorf("unknown http log sink format %q (must be json, loki or elastic)", cfg.Format)
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSinkBatchSize
+	}
+
+	s := &httpSink{
+		url:     cfg.URL,
+		format:  format,
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	s.batchSink = newBatchSink(cfg.URL, cfg, batchSize, s.deliver)
+	go s.run()
+	return s, nil
+}
+
+// deliver posts one batch. Client errors other than 408/429 are permanent.
+func (s *httpSink) deliver(batch []sinkEntry) error {
+	body, contentType, err := encodeSinkBatch(s.format, batch)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "service-manager/1.0")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return permanentError{fmt.Errorf("endpoint rejected batch with status %d", resp.StatusCode)}
+}
+
+// encodeSinkBatch encodes a batch in an HTTP sink format and returns the content type
+func encodeSinkBatch(format string, batch []sinkEntry) ([]byte, string, error) {
+	switch format {
+	case httpSinkLoki:
+		// One Loki stream per service and output stream, values in arrival order
+		type lokiStream struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		}
+		var streams []*lokiStream
+		index := map[[2]string]*lokiStream{}
+		for _, entry := range batch {
+			key := [2]string{entry.service, entry.line.Stream}
+			stream, ok := index[key]
+			if !ok {
+				stream = &lokiStream{Stream: map[string]string{"service": entry.service, "stream": entry.line.Stream}}
+				index[key] = stream
+				streams = append(streams, stream)
+			}
+			stream.Values = append(stream.Values, [2]string{strconv.FormatInt(entry.line.Time.UnixNano(), 10), entry.line.Text})
+		}
+		data, err := json.Marshal(map[string]any{"streams": streams})
+		return data, "application/json", err
+
+	case httpSinkElastic:
+		// Bulk API: an action line before every document
+		var buf bytes.Buffer
+		for _, entry := range batch {
+			buf.WriteString(`{"create":{}}` + "\n")
+			data, err := json.Marshal(elasticDocument{
+				Timestamp: entry.line.Time,
+				Message:   entry.line.Text,
+				Service:   entry.service,
+				Stream:    entry.line.Stream,
+				RunID:     entry.line.RunID,
+				PID:       entry.line.PID,
+				Level:     entry.line.Level,
+			})
+			if err != nil {
+				return nil, "", err
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), "application/x-ndjson", nil
+	}
+
+	docs := make([]sinkDocument, len(batch))
+	for i, entry := range batch {
+		docs[i] = sinkDocument{Service: entry.service, LogLine: entry.line}
+		docs[i].Offset, docs[i].HTML, docs[i].Seq = 0, "", 0 // Only meaningful for the local log file and UI
+	}
+	data, err := json.Marshal(docs)
+	return data, "application/json", err
+}
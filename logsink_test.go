@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyslogSink_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := NewLogSink(LogSinkConfig{Type: LogSinkSyslog, Address: "udp://" + conn.LocalAddr().String(), Facility: "local0"})
+	if err != nil {
+		t.Fatalf("NewLogSink failed: %v", err)
+	}
+	defer sink.Close()
+
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sink.Send("my app", LogLine{Time: when, Stream: "stderr", PID: 42, Text: "disk full", Level: "error"})
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("No syslog message received: %v", err)
+	}
+
+	// local0 (16) * 8 + error (3) = 131
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<131>1 2024-05-01T12:00:00.000000Z ") {
+		t.Errorf("Unexpected header: %q", msg)
+	}
+	if !strings.HasSuffix(msg, " my_app 42 stderr - disk full") {
+		t.Errorf("Unexpected message: %q", msg)
+	}
+}
+
+func TestNewLogSink_Invalid(t *testing.T) {
+	tests := []LogSinkConfig{
+		{Type: "kafka"},
+		{Type: LogSinkSyslog, Address: "tcp://localhost:514"},
+		{Type: LogSinkSyslog, Facility: "nope"},
+		{Type: LogSinkHTTP},
+		{Type: LogSinkHTTP, URL: "http://localhost", Format: "xml"},
+	}
+	for _, cfg := range tests {
+		if sink, err := NewLogSink(cfg); err == nil {
+			sink.Close()
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestHTTPSink_BatchesAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // First attempt fails and is retried
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			t.Errorf("Missing configured header")
+		}
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+	}))
+	defer server.Close()
+
+	sink, err := NewLogSink(LogSinkConfig{
+		Type:          LogSinkHTTP,
+		URL:           server.URL,
+		Format:        "loki",
+		Headers:       map[string]string{"X-Token": "secret"},
+		BatchSize:     3,
+		FlushInterval: "50ms",
+	})
+	if err != nil {
+		t.Fatalf("NewLogSink failed: %v", err)
+	}
+
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sink.Send("web", LogLine{Time: when, Stream: "stdout", Text: "one"})
+	sink.Send("web", LogLine{Time: when, Stream: "stderr", Text: "two"})
+	sink.Send("web", LogLine{Time: when, Stream: "stdout", Text: "three"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		got := len(bodies)
+		mu.Unlock()
+		if got > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one delivered batch after the retry, got %d", len(bodies))
+	}
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &push); err != nil {
+		t.Fatalf("Invalid Loki payload: %v", err)
+	}
+	if len(push.Streams) != 2 {
+		t.Fatalf("Expected stdout and stderr streams, got %+v", push.Streams)
+	}
+	stdout := push.Streams[0]
+	if stdout.Stream["service"] != "web" || stdout.Stream["stream"] != "stdout" || len(stdout.Values) != 2 {
+		t.Errorf("Unexpected stdout stream: %+v", stdout)
+	}
+	if stdout.Values[1][1] != "three" || stdout.Values[0][0] != "1714564800000000000" {
+		t.Errorf("Unexpected values: %+v", stdout.Values)
+	}
+}
+
+func TestSinkQueue_DropsOldest(t *testing.T) {
+	q := newSinkQueue(2)
+	for _, text := range []string{"a", "b", "c"} {
+		q.push(sinkEntry{service: "svc", line: LogLine{Text: text}})
+	}
+
+	batch, dropped := q.pop(10)
+	if dropped != 1 {
+		t.Errorf("Expected 1 dropped entry, got %d", dropped)
+	}
+	if len(batch) != 2 || batch[0].line.Text != "b" || batch[1].line.Text != "c" {
+		t.Errorf("Expected the newest entries, got %+v", batch)
+	}
+}
+
+func TestEncodeSinkBatch_Elastic(t *testing.T) {
+	batch := []sinkEntry{{service: "api", line: LogLine{Time: time.Unix(0, 0).UTC(), Stream: "stdout", Text: "hi", Level: "info"}}}
+	body, contentType, err := encodeSinkBatch(httpSinkElastic, batch)
+	if err != nil {
+		t.Fatalf("encodeSinkBatch failed: %v", err)
+	}
+	if contentType != "application/x-ndjson" {
+		t.Errorf("Unexpected content type %q", contentType)
+	}
+	want := `{"create":{}}` + "\n" + `{"@timestamp":"1970-01-01T00:00:00Z","message":"hi","service":"api","stream":"stdout","level":"info"}` + "\n"
+	if string(body) != want {
+		t.Errorf("Unexpected body:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestServiceManager_ClosesUnusedLogSinks(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+
+	web := ServiceConfig{Name: "web", Command: "true", Type: ServiceTypeManual,
+		LogSinks: []LogSinkConfig{{Type: LogSinkHTTP, URL: "http://127.0.0.1:1/old"}}}
+	m.OnServicesUpdated([]ServiceConfig{web}, nil)
+
+	m.mu.RLock()
+	old := m.sinks[web.LogSinks[0].key()].(*httpSink)
+	m.mu.RUnlock()
+
+	web.LogSinks = []LogSinkConfig{{Type: LogSinkHTTP, URL: "http://127.0.0.1:1/new"}}
+	m.OnServicesUpdated([]ServiceConfig{web}, []string{"web"})
+
+	m.mu.RLock()
+	count := len(m.sinks)
+	m.mu.RUnlock()
+	if count != 1 {
+		t.Errorf("Expected only the new sink, got %d sinks", count)
+	}
+	select {
+	case <-old.done:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the replaced sink to be closed")
+	}
+}
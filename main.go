@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 )
 
@@ -35,6 +36,11 @@ func main() {
 	// Create config manager for services
 	configManager := NewConfigManager("services.yaml")
 
+	// Record state-changing actions (API, external config edits, automatic restarts)
+	auditLog := NewAuditLog(filepath.Join(globalConfig.EffectiveLogDir(), "audit.jsonl"))
+	serviceManager.SetAuditLog(auditLog)
+	configManager.SetAuditLog(auditLog)
+
 	// Start watching for config file changes (loads initial config, emits initial state, and watches for changes)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -72,5 +78,6 @@ func main() {
 	cancel() // Stop config watcher
 	configManager.Stop()
 	serviceManager.StopAll()
+	waitLogCompression() // Finish compressing rotated logs
 	fmt.Println("Service Manager stopped")
 }
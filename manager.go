@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,10 +16,16 @@ type ServiceManager struct {
 	order           []string // Maintains service order from YAML
 	cronScheduler   *cron.Cron
 	cronEntries     map[string]cron.EntryID // Maps service name to cron entry ID
+	watchers        map[string]*FileWatcher // Maps service name to its file-change trigger
 	globalConfig    GlobalConfig
 	webhookNotifier *Notifier
-	webhookSent     map[string]bool // Track if webhook was sent for a service (reset on success)
-	webhookWg       sync.WaitGroup  // Track pending webhook goroutines
+	webhookSent     map[string]bool    // Track if webhook was sent for a service (reset on success)
+	webhookWg       sync.WaitGroup     // Track pending webhook goroutines
+	pending         map[string]bool    // Services waiting for their "after" dependencies to succeed
+	sinks           map[string]LogSink // Log sinks by configuration key (shared between services)
+	sinkCloseWg     sync.WaitGroup     // Track sinks that are flushed after no service uses them anymore
+	events          *EventBus          // State changes of the services, for the API
+	audit           *AuditLog          // Records automatic restarts (nil = no audit log)
 	mu              sync.RWMutex
 }
 
@@ -32,7 +39,11 @@ func NewServiceManager(globalConfig GlobalConfig) *ServiceManager {
 		order:           make([]string, 0),
 		cronScheduler:   cronScheduler,
 		cronEntries:     make(map[string]cron.EntryID),
+		watchers:        make(map[string]*FileWatcher),
 		webhookSent:     make(map[string]bool),
+		pending:         make(map[string]bool),
+		sinks:           make(map[string]LogSink),
+		events:          NewEventBus(),
 		globalConfig:    globalConfig,
 		webhookNotifier: NewNotifier(globalConfig.FailureWebhookURL),
 	}
@@ -59,37 +70,51 @@ func (m *ServiceManager) OnServicesUpdated(services []ServiceConfig, toKill []st
 		}
 	}
 
-	// Step 2: Kill services that need to be stopped
+	// Step 2: Build new service map and order
+	newServiceMap := make(map[string]ServiceConfig)
+	newOrder := make([]string, 0, len(services))
+	for _, svc := range services {
+		newServiceMap[svc.Name] = svc
+		newOrder = append(newOrder, svc.Name)
+	}
+	problems := serviceProblems(services, m.globalConfig)
+	for _, name := range newOrder {
+		if problem, exists := problems[name]; exists {
+			fmt.Printf("[Manager]   Invalid: %s (%s)\n", name, problem)
+		}
+	}
+
+	// Step 3: Kill services that need to be stopped
 	if len(toKill) > 0 {
 		fmt.Printf("[Manager]   ToKill: %v\n", toKill)
 		for _, name := range toKill {
 			if state, exists := m.services[name]; exists {
 				fmt.Printf("[Manager]     Stopping: %s (was running: %v)\n", name, wasRunning[name])
 				m.unscheduleService(name)
+				m.unwatchService(name)
 				// Only call Stop if the service is actually running
 				if state.IsRunning() {
 					state.Stop()
 				}
 				delete(m.services, name)
+				delete(m.pending, name)
+				if _, exists := newServiceMap[name]; !exists {
+					m.events.Publish(Event{Type: EventRemoved, Service: name})
+				}
 			}
 		}
 	}
 
-	// Step 3: Build new service map and order
-	newServiceMap := make(map[string]ServiceConfig)
-	newOrder := make([]string, 0, len(services))
-	for _, svc := range services {
-		newServiceMap[svc.Name] = svc
-		newOrder = append(newOrder, svc.Name)
-	}
-
 	// Step 4: Remove services no longer in config
 	for name := range m.services {
 		if _, exists := newServiceMap[name]; !exists {
 			fmt.Printf("[Manager]   Removing: %s (no longer in config)\n", name)
 			m.unscheduleService(name)
+			m.unwatchService(name)
 			m.services[name].Stop()
 			delete(m.services, name)
+			delete(m.pending, name)
+			m.events.Publish(Event{Type: EventRemoved, Service: name})
 		}
 	}
 
@@ -101,8 +126,13 @@ func (m *ServiceManager) OnServicesUpdated(services []ServiceConfig, toKill []st
 		if !exists {
 			// Service not in map - could be new, or recreated after config change
 			newCount++
-			state = NewService(svc)
+			state = NewService(svc, m.globalConfig)
 			state.SetFailureCallback(m.handleServiceFailure)
+			state.SetExitCallback(m.handleServiceExit)
+			state.SetAlertCallback(m.handleLogAlert)
+			state.SetLogSinks(m.logSinks(svc.EffectiveLogSinks(m.globalConfig)))
+			state.SetEventBus(m.events)
+			state.SetAuditLog(m.audit)
 			m.services[svc.Name] = state
 
 			// Determine if we should start the service
@@ -118,11 +148,14 @@ func (m *ServiceManager) OnServicesUpdated(services []ServiceConfig, toKill []st
 					// Enabling a disabled service → force start
 					shouldStart = true
 					reason = "enabled"
+					m.events.Publish(Event{Type: EventEnabled, Service: svc.Name})
 				} else if oldEnabled && !newEnabled {
 					// Disabling an enabled service → don't start
 					shouldStart = false
 					reason = "disabled"
+					m.events.Publish(Event{Type: EventDisabled, Service: svc.Name})
 				} else {
+					m.events.Publish(Event{Type: EventUpdated, Service: svc.Name})
 					// Enabled flag didn't change → preserve runtime state
 					shouldStart = wasRunning[svc.Name] && newEnabled
 					if wasRunning[svc.Name] {
@@ -138,18 +171,43 @@ func (m *ServiceManager) OnServicesUpdated(services []ServiceConfig, toKill []st
 				// Truly new service → start if enabled
 				shouldStart = svc.IsEnabled()
 				reason = "new service"
+				m.events.Publish(Event{Type: EventCreated, Service: svc.Name})
 				fmt.Printf("[Manager]     Creating new: %s (enabled: %v)\n", svc.Name, svc.IsEnabled())
 			}
 
+			// Manual services are never started automatically
+			if shouldStart && svc.IsManual() {
+				shouldStart = false
+				reason = "manual service"
+			}
+
+			if problem, exists := problems[svc.Name]; exists && shouldStart {
+				shouldStart = false
+				reason = problem
+			}
+
+			// Watch triggers are armed for every enabled watched service, whether or not it was
+			// running before a config change (a manual or scheduled job is idle most of the time)
+			if _, invalid := problems[svc.Name]; svc.IsEnabled() && svc.IsWatched() && !invalid {
+				m.watchService(svc.Name, state)
+				fmt.Printf("[Manager]     Watching: %s (%v)\n", svc.Name, svc.Watch.Paths)
+			} else if svc.Watch != nil && !svc.IsScheduled() && !svc.IsManual() {
+				fmt.Printf("[Manager]     Ignoring watch of %s: only scheduled and manual services can be watched\n", svc.Name)
+			}
+
 			if shouldStart {
 				if svc.IsScheduled() {
 					if err := m.scheduleService(svc.Name, state); err != nil {
 						fmt.Printf("[Manager]     Failed to schedule %s: %v\n", svc.Name, err)
 					} else {
 						fmt.Printf("[Manager]     Scheduled: %s (%s)\n", svc.Name, svc.Schedule)
+						m.catchUp(state)
 					}
+				} else if unmet := m.unmetDependencies(svc); len(unmet) > 0 {
+					m.pending[svc.Name] = true
+					fmt.Printf("[Manager]     Waiting: %s (after %v)\n", svc.Name, unmet)
 				} else {
-					if err := state.Start(); err != nil {
+					if err := state.StartRun(RunOptions{Trigger: TriggerStartup}); err != nil {
 						fmt.Printf("[Manager]     Failed to start %s: %v\n", svc.Name, err)
 					} else {
 						fmt.Printf("[Manager]     Started: %s\n", svc.Name)
@@ -164,14 +222,53 @@ func (m *ServiceManager) OnServicesUpdated(services []ServiceConfig, toKill []st
 		}
 	}
 
+	// Services waiting for a dependency that was removed (or now forms a cycle) would wait forever
+	for _, name := range newOrder {
+		if !m.pending[name] {
+			continue
+		}
+		if problem, exists := problems[name]; exists {
+			delete(m.pending, name)
+			fmt.Printf("[Manager]   No longer waiting: %s (%s)\n", name, problem)
+			continue
+		}
+		for _, dep := range m.unmetDependencies(newServiceMap[name]) {
+			if depConfig := newServiceMap[dep]; !depConfig.IsEnabled() {
+				fmt.Printf("[Manager]   Warning: %s is waiting for %s, which is disabled\n", name, dep)
+			}
+		}
+	}
+
 	// Update order
 	m.order = newOrder
 
+	m.closeUnusedLogSinks()
+
 	if newCount > 0 {
 		fmt.Printf("[Manager]   Created: %d new services\n", newCount)
 	}
 
 	fmt.Printf("[Manager] Update complete. Total services: %d\n", len(m.services))
+	m.events.Publish(Event{Type: EventConfigReloaded})
+}
+
+// SetAuditLog sets the log that records the automatic actions of the services created from now on
+func (m *ServiceManager) SetAuditLog(audit *AuditLog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = audit
+}
+
+// AuditLog returns the audit log (nil if not set)
+func (m *ServiceManager) AuditLog() *AuditLog {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.audit
+}
+
+// Events returns the bus the state changes of the services are published to
+func (m *ServiceManager) Events() *EventBus {
+	return m.events
 }
 
 // GetGlobalConfig returns the global configuration
@@ -209,6 +306,13 @@ func (m *ServiceManager) GetAllServices() []*Service {
 	return services
 }
 
+// IsPending returns whether a service is waiting for its "after" dependencies
+func (m *ServiceManager) IsPending(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pending[name]
+}
+
 // StartService starts a service by name (runtime control only)
 func (m *ServiceManager) StartService(name string) error {
 	svc, err := m.GetService(name)
@@ -216,6 +320,11 @@ func (m *ServiceManager) StartService(name string) error {
 		return err
 	}
 
+	// A manual start overrides waiting for dependencies
+	m.mu.Lock()
+	delete(m.pending, name)
+	m.mu.Unlock()
+
 	return svc.Start()
 }
 
@@ -226,6 +335,11 @@ func (m *ServiceManager) StopService(name string) error {
 		return err
 	}
 
+	// A stopped service should not be started later by its dependencies
+	m.mu.Lock()
+	delete(m.pending, name)
+	m.mu.Unlock()
+
 	return svc.Stop()
 }
 
@@ -239,6 +353,21 @@ func (m *ServiceManager) RestartService(name string) error {
 	return svc.Restart()
 }
 
+// RunService immediately runs a scheduled, oneshot or manual service (runtime control only)
+// Returns an error if the service is continuous or already running (overlap prevention).
+func (m *ServiceManager) RunService(name string, opts RunOptions) error {
+	svc, err := m.GetService(name)
+	if err != nil {
+		return err
+	}
+
+	if svc.Config.IsContinuous() {
+		return fmt.Errorf("service %s is a continuous service and cannot be run on demand", name)
+	}
+
+	return svc.StartRun(opts)
+}
+
 // scheduleService adds a service to the cron scheduler
 func (m *ServiceManager) scheduleService(name string, svc *Service) error {
 	// Remove existing schedule if any
@@ -255,7 +384,7 @@ func (m *ServiceManager) scheduleService(name string, svc *Service) error {
 		}
 
 		// Start the service
-		if err := svc.Start(); err != nil {
+		if err := svc.StartRun(RunOptions{Trigger: TriggerCron}); err != nil {
 			fmt.Printf("Failed to start scheduled service %s: %v\n", name, err)
 		}
 	})
@@ -268,6 +397,31 @@ func (m *ServiceManager) scheduleService(name string, svc *Service) error {
 	return nil
 }
 
+// catchUp starts a scheduled service with catch_up if a scheduled run was missed since its last
+// run (e.g. while the manager was not running). Services that never ran are not caught up.
+func (m *ServiceManager) catchUp(svc *Service) {
+	if !svc.Config.CatchUp || svc.IsRunning() {
+		return
+	}
+	last, ok := svc.LastRun()
+	if !ok {
+		return
+	}
+	schedule, err := cron.ParseStandard(svc.Config.Schedule)
+	if err != nil {
+		return
+	}
+	missed := schedule.Next(last.StartTime)
+	if missed.After(time.Now()) {
+		return
+	}
+
+	fmt.Printf("[Manager]     Catching up: %s (missed run at %s)\n", svc.Config.Name, missed.Format("2006-01-02 15:04"))
+	if err := svc.StartRun(RunOptions{Trigger: TriggerCatchUp}); err != nil {
+		fmt.Printf("[Manager]     Failed to catch up %s: %v\n", svc.Config.Name, err)
+	}
+}
+
 // unscheduleService removes a service from the cron scheduler
 func (m *ServiceManager) unscheduleService(name string) {
 	if entryID, exists := m.cronEntries[name]; exists {
@@ -276,6 +430,45 @@ func (m *ServiceManager) unscheduleService(name string) {
 	}
 }
 
+// watchService starts the file-change trigger of a service
+// Caller must hold the lock
+func (m *ServiceManager) watchService(name string, svc *Service) {
+	// Remove existing watcher if any
+	m.unwatchService(name)
+
+	watch := svc.Config.Watch
+	watcher := NewFileWatcher(watch.Paths, svc.Config.Workdir, watch.DebounceDuration(), func(changed []string) {
+		// Check if already running (overlap prevention)
+		if svc.IsRunning() {
+			logMsg := fmt.Sprintf("[%s] Watch run skipped: previous instance still running (%d changed files)\n",
+				time.Now().Format("2006-01-02 15:04:05"), len(changed))
+			svc.WriteStderrLog(logMsg)
+			return
+		}
+
+		// Pass the changed files to the job
+		opts := RunOptions{
+			Trigger: TriggerWatch,
+			Env:     map[string]string{watchChangedFilesEnv: strings.Join(changed, string(os.PathListSeparator))},
+		}
+		if err := svc.StartRun(opts); err != nil {
+			fmt.Printf("Failed to start watched service %s: %v\n", name, err)
+		}
+	})
+	watcher.Start()
+
+	m.watchers[name] = watcher
+}
+
+// unwatchService stops the file-change trigger of a service
+// Caller must hold the lock
+func (m *ServiceManager) unwatchService(name string) {
+	if watcher, exists := m.watchers[name]; exists {
+		watcher.Stop()
+		delete(m.watchers, name)
+	}
+}
+
 // GetNextRunTime returns the next scheduled run time for a service
 func (m *ServiceManager) GetNextRunTime(name string) (time.Time, bool) {
 	m.mu.RLock()
@@ -299,10 +492,24 @@ func (m *ServiceManager) StopAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Stop file watchers
+	for name := range m.watchers {
+		m.unwatchService(name)
+	}
+
 	for _, svc := range m.services {
 		svc.Stop()
 	}
 
+	// Flush the log sinks
+	for key, sink := range m.sinks {
+		if err := sink.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		delete(m.sinks, key)
+	}
+	m.sinkCloseWg.Wait()
+
 	// Wait for pending webhooks with timeout
 	done := make(chan struct{})
 	go func() {
@@ -319,6 +526,146 @@ func (m *ServiceManager) StopAll() {
 	}
 }
 
+// logSinks returns the sinks for the given configurations, creating them on first use.
+// Invalid configurations are reported and skipped.
+// Caller must hold the lock
+func (m *ServiceManager) logSinks(cfgs []LogSinkConfig) []LogSink {
+	var sinks []LogSink
+	for _, cfg := range cfgs {
+		key := cfg.key()
+		sink, exists := m.sinks[key]
+		if !exists {
+			var err error
+			sink, err = NewLogSink(cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[LogSink] Invalid log sink: %v\n", err)
+				continue
+			}
+			m.sinks[key] = sink
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// closeUnusedLogSinks flushes and closes the sinks that no service uses anymore, e.g. after a
+// service's log_sinks changed. Closing waits for the last delivery, so it runs in the background.
+// Caller must hold the lock
+func (m *ServiceManager) closeUnusedLogSinks() {
+	used := make(map[string]bool)
+	for _, svc := range m.services {
+		for _, cfg := range svc.Config.EffectiveLogSinks(m.globalConfig) {
+			used[cfg.key()] = true
+		}
+	}
+	for key, sink := range m.sinks {
+		if used[key] {
+			continue
+		}
+		delete(m.sinks, key)
+		m.sinkCloseWg.Add(1)
+		go func() {
+			defer m.sinkCloseWg.Done()
+			if err := sink.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+}
+
+// unmetDependencies returns the "after" dependencies of a service that have not finished successfully yet
+// Caller must hold the lock
+func (m *ServiceManager) unmetDependencies(cfg ServiceConfig) []string {
+	var unmet []string
+	for _, dep := range cfg.After {
+		if svc, exists := m.services[dep]; !exists || !svc.HasSucceeded() {
+			unmet = append(unmet, dep)
+		}
+	}
+	return unmet
+}
+
+// handleServiceExit is called every time a service process exits.
+// It runs the on_success/on_failure services and starts services that were waiting for this one to finish successfully.
+func (m *ServiceManager) handleServiceExit(serviceName string, run RunRecord) {
+	// Intentional stops neither succeed nor fail
+	if run.Stopped {
+		return
+	}
+
+	m.triggerChainedServices(serviceName, run)
+
+	if run.ExitCode != 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, name := range m.order {
+		if !m.pending[name] {
+			continue
+		}
+		svc, exists := m.services[name]
+		if !exists {
+			delete(m.pending, name)
+			continue
+		}
+		if len(m.unmetDependencies(svc.Config)) > 0 {
+			continue
+		}
+
+		delete(m.pending, name)
+		fmt.Printf("[Manager] Dependencies of %s finished, starting\n", name)
+		if err := svc.StartRun(RunOptions{Trigger: TriggerStartup}); err != nil {
+			fmt.Printf("[Manager] Failed to start %s: %v\n", name, err)
+		}
+	}
+}
+
+// triggerChainedServices runs the on_success or on_failure services of a finished run (as run-now does)
+func (m *ServiceManager) triggerChainedServices(serviceName string, run RunRecord) {
+	svc, err := m.GetService(serviceName)
+	if err != nil {
+		return
+	}
+
+	targets, trigger := svc.Config.OnSuccess, TriggerOnSuccess
+	if run.ExitCode != 0 || run.Error != "" {
+		targets, trigger = svc.Config.OnFailure, TriggerOnFailure
+	}
+
+	for _, name := range targets {
+		target, err := m.GetService(name)
+		if err != nil {
+			fmt.Printf("[Manager] Cannot trigger %s from %s: %v\n", name, serviceName, err)
+			continue
+		}
+		if !target.Config.IsEnabled() {
+			fmt.Printf("[Manager] Not triggering %s from %s: service is disabled\n", name, serviceName)
+			continue
+		}
+
+		// Overlap prevention: skip if the previous run is still running
+		if target.IsRunning() {
+			logMsg := fmt.Sprintf("[%s] Triggered run skipped (%s of %s): previous instance still running\n",
+				time.Now().Format("2006-01-02 15:04:05"), trigger, serviceName)
+			target.WriteStderrLog(logMsg)
+			continue
+		}
+
+		opts := RunOptions{
+			Trigger: trigger,
+			Env:     map[string]string{triggeredByEnv: serviceName},
+		}
+		if err := m.RunService(name, opts); err != nil {
+			fmt.Printf("[Manager] Failed to trigger %s from %s: %v\n", name, serviceName, err)
+		} else {
+			fmt.Printf("[Manager] Triggered %s (%s of %s)\n", name, trigger, serviceName)
+		}
+	}
+}
+
 // handleServiceFailure is called when a service fails or succeeds (to reset state)
 // Note: This callback is triggered on every service exit, not just failures
 func (m *ServiceManager) handleServiceFailure(serviceName string, consecutiveFailures int, exitCode int, err error) {
@@ -370,3 +717,31 @@ func (m *ServiceManager) handleServiceFailure(serviceName string, consecutiveFai
 		m.webhookSent[serviceName] = true
 	}
 }
+
+// handleLogAlert sends the webhook for an output line that matched one of the service's alerts
+func (m *ServiceManager) handleLogAlert(serviceName string, alert LogAlert) {
+	fmt.Printf("Alert for service %s: %q matched %q\n", serviceName, alert.Pattern, alert.Line.Text)
+
+	if m.webhookNotifier == nil {
+		return
+	}
+
+	payload := AlertPayload{
+		ServiceName: serviceName,
+		Timestamp:   alert.Line.Time,
+		Event:       "log_alert",
+		Pattern:     alert.Pattern,
+		Stream:      alert.Line.Stream,
+		Line:        alert.Line.Text,
+		Before:      logLineTexts(alert.Before),
+		After:       logLineTexts(alert.After),
+	}
+
+	m.webhookWg.Add(1)
+	go func() {
+		defer m.webhookWg.Done()
+		if err := m.webhookNotifier.NotifyAlert(payload); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send alert webhook for service %s: %v\n", serviceName, err)
+		}
+	}()
+}
@@ -3,11 +3,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -74,8 +78,8 @@ func TestChildProcessTreeCleanup(t *testing.T) {
 		if len(services) > 0 {
 			stdout := services[0].GetStdoutBuffer()
 			stderr := services[0].GetStderrBuffer()
-			t.Logf("Service stdout: %s", string(stdout))
-			t.Logf("Service stderr: %s", string(stderr))
+			t.Logf("Service stdout: %v", stdout)
+			t.Logf("Service stderr: %v", stderr)
 		}
 		t.Fatalf("expected at least 1 grandchild process")
 	}
@@ -150,3 +154,334 @@ func countGoRunProcesses(serviceName, testServiceDir string) (int, error) {
 	}
 	return lines, nil
 }
+
+// Verifies that services with an invalid type or "after" dependencies that can never be met
+// are not started and don't keep waiting.
+func TestInvalidServicesNotStarted(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+
+	gate := ServiceConfig{Name: "gate", Command: "sleep 10", Type: ServiceTypeManual}
+	waiting := ServiceConfig{Name: "waiting", Command: "sleep 10", After: []string{"gate"}}
+	services := []ServiceConfig{
+		{Name: "typo", Command: "sleep 10", Type: "oneshoot"},
+		{Name: "orphan", Command: "sleep 10", After: []string{"missing"}},
+		{Name: "a", Command: "sleep 10", After: []string{"b"}},
+		{Name: "b", Command: "sleep 10", After: []string{"a"}},
+		gate,
+		waiting,
+	}
+	m.OnServicesUpdated(services, nil)
+
+	for _, name := range []string{"typo", "orphan", "a", "b"} {
+		svc, err := m.GetService(name)
+		if err != nil {
+			t.Fatalf("get service %s: %v", name, err)
+		}
+		if svc.IsRunning() || m.IsPending(name) {
+			t.Errorf("expected %s to be neither running nor waiting", name)
+		}
+	}
+	if !m.IsPending("waiting") {
+		t.Fatalf("expected waiting to wait for gate")
+	}
+
+	// Removing the dependency stops the wait
+	m.OnServicesUpdated([]ServiceConfig{waiting}, []string{"gate"})
+	if m.IsPending("waiting") {
+		t.Errorf("expected waiting to stop waiting for the removed gate")
+	}
+}
+
+// Verifies that oneshot services run exactly once and that services waiting for them
+// via "after" are started only once they finished successfully.
+func TestOneshotServiceWithDependents(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+
+	setup := ServiceConfig{Name: "setup", Command: "sh -c 'sleep 0.3'", Type: ServiceTypeOneshot}
+	worker := ServiceConfig{Name: "worker", Command: "sh -c 'echo done'", Type: ServiceTypeOneshot, After: []string{"setup"}}
+	blocked := ServiceConfig{Name: "blocked", Command: "sleep 10", After: []string{"broken"}}
+	broken := ServiceConfig{Name: "broken", Command: "sh -c 'exit 3'", Type: ServiceTypeOneshot}
+
+	m.OnServicesUpdated([]ServiceConfig{setup, worker, broken, blocked}, nil)
+
+	if !m.IsPending("worker") {
+		t.Fatalf("expected worker to wait for setup")
+	}
+
+	// setup takes 300ms, then worker runs; oneshots must not be restarted afterwards
+	time.Sleep(restartDelay + time.Second)
+
+	for _, name := range []string{"setup", "worker", "broken"} {
+		svc, err := m.GetService(name)
+		if err != nil {
+			t.Fatalf("get service %s: %v", name, err)
+		}
+		if _, total := svc.GetRuns(0, 10); total != 1 {
+			t.Errorf("expected %s to run exactly once, got %d runs", name, total)
+		}
+	}
+
+	if m.IsPending("worker") {
+		t.Errorf("expected worker to be started after setup succeeded")
+	}
+	if !m.IsPending("blocked") {
+		t.Errorf("expected blocked to keep waiting for failed dependency")
+	}
+	if svc, _ := m.GetService("blocked"); svc.IsRunning() {
+		t.Errorf("expected blocked not to be running")
+	}
+}
+
+func TestManualServiceRunWithParameters(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+
+	manual := ServiceConfig{Name: "reindex", Command: "sh -c 'echo \"$0 $1 $CUSTOMER\"' reindex", Type: ServiceTypeManual}
+	web := ServiceConfig{Name: "web", Command: "sleep 10"}
+
+	m.OnServicesUpdated([]ServiceConfig{manual, web}, nil)
+
+	svc, err := m.GetService("reindex")
+	if err != nil {
+		t.Fatalf("get service: %v", err)
+	}
+	if svc.IsRunning() {
+		t.Fatalf("expected manual service not to be started automatically")
+	}
+
+	if err := m.RunService("web", RunOptions{Trigger: TriggerRunNow}); err == nil || !strings.Contains(err.Error(), "cannot be run") {
+		t.Errorf("expected continuous service to be rejected, got %v", err)
+	}
+
+	opts := RunOptions{Trigger: TriggerRunNow, Args: []string{"--full"}, Env: map[string]string{"CUSTOMER": "1234"}}
+	if err := m.RunService("reindex", opts); err != nil {
+		t.Fatalf("run service: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	runs, total := svc.GetRuns(0, 10)
+	if total != 1 {
+		t.Fatalf("expected exactly one run, got %d", total)
+	}
+	if runs[0].Trigger != TriggerRunNow || len(runs[0].Args) != 1 || len(runs[0].EnvKeys) != 1 || runs[0].EnvKeys[0] != "CUSTOMER" {
+		t.Errorf("expected run parameters to be recorded, got %+v", runs[0])
+	}
+
+	output, err := os.ReadFile(filepath.Join("logs", "reindex-stdout.log"))
+	if err != nil {
+		t.Fatalf("read stdout log: %v", err)
+	}
+	if !strings.Contains(string(output), "reindex --full 1234") {
+		t.Errorf("expected extra args and env in output, got %q", output)
+	}
+}
+
+// Verifies that run log retention never deletes the service's own log files, even when they
+// are written to the same directory tree as the run logs.
+func TestRunLogRetentionKeepsServiceLog(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+
+	job := ServiceConfig{Name: "job", Command: "echo run", Type: ServiceTypeManual,
+		StdoutPath: "{name}/{date}.log", StderrPath: "{name}/stderr.log", RunLogs: &RunLogsConfig{Keep: 1}}
+	m.OnServicesUpdated([]ServiceConfig{job}, nil)
+
+	svc, err := m.GetService("job")
+	if err != nil {
+		t.Fatalf("get service: %v", err)
+	}
+	for range 3 {
+		if err := m.RunService("job", RunOptions{Trigger: TriggerRunNow}); err != nil {
+			t.Fatalf("run service: %v", err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for svc.IsRunning() && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	stdoutLog := filepath.Join("logs", "job", time.Now().Format("2006-01-02")+".log")
+	if data, err := os.ReadFile(stdoutLog); err != nil || strings.Count(string(data), "run") < 3 {
+		t.Errorf("expected the stdout log to keep the output of all runs, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join("logs", "job", "stderr.log")); err != nil {
+		t.Errorf("expected the stderr log to be kept: %v", err)
+	}
+	runLogs, _ := filepath.Glob(filepath.Join("logs", "job", "runs", "*.log"))
+	runs, _ := svc.GetRuns(0, 10)
+	if len(runLogs) != 1 || len(runs) != 3 || runLogs[0] != runs[0].LogFile {
+		t.Errorf("expected only the newest run log, got %v", runLogs)
+	}
+}
+
+func TestChainedServicesOnSuccessAndFailure(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+
+	extract := ServiceConfig{Name: "extract", Command: "true", Type: ServiceTypeManual, OnSuccess: []string{"transform"}, OnFailure: []string{"alert"}}
+	transform := ServiceConfig{Name: "transform", Command: "false", Type: ServiceTypeManual, OnSuccess: []string{"load"}, OnFailure: []string{"alert"}}
+	load := ServiceConfig{Name: "load", Command: "true", Type: ServiceTypeManual}
+	alert := ServiceConfig{Name: "alert", Command: "true", Type: ServiceTypeManual}
+
+	m.OnServicesUpdated([]ServiceConfig{extract, transform, load, alert}, nil)
+
+	if err := m.RunService("extract", RunOptions{Trigger: TriggerRunNow}); err != nil {
+		t.Fatalf("run service: %v", err)
+	}
+
+	// extract succeeds → transform fails → alert runs; load never runs
+	alertSvc, _ := m.GetService("alert")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, total := alertSvc.GetRuns(0, 1); total > 0 && !alertSvc.IsRunning() {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	runs, total := alertSvc.GetRuns(0, 10)
+	if total != 1 {
+		t.Fatalf("expected alert to run once, got %d runs", total)
+	}
+	if runs[0].Trigger != TriggerOnFailure || len(runs[0].EnvKeys) != 1 || runs[0].EnvKeys[0] != triggeredByEnv {
+		t.Errorf("expected alert to be triggered by transform failure, got %+v", runs[0])
+	}
+
+	transformSvc, _ := m.GetService("transform")
+	if runs, total := transformSvc.GetRuns(0, 10); total != 1 || runs[0].Trigger != TriggerOnSuccess {
+		t.Errorf("expected transform to be triggered once by extract success, got %d runs", total)
+	}
+
+	loadSvc, _ := m.GetService("load")
+	if _, total := loadSvc.GetRuns(0, 10); total != 0 {
+		t.Errorf("expected load not to run after transform failed, got %d runs", total)
+	}
+}
+
+func TestLogAlertFiresWebhook(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	payloads := make(chan AlertPayload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload AlertPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	m := NewServiceManager(GlobalConfig{FailureWebhookURL: server.URL})
+	defer m.StopAll()
+
+	context := 2
+	worker := ServiceConfig{
+		Name:    "worker",
+		Command: `sh -c "echo starting >&2; echo 'FATAL: queue unreachable' >&2; echo retrying >&2; echo degraded >&2; sleep 10"`,
+		Alerts:  []AlertConfig{{Pattern: "FATAL|panic:", Stream: "stderr", Context: &context}},
+	}
+	m.OnServicesUpdated([]ServiceConfig{worker}, nil)
+
+	select {
+	case payload := <-payloads:
+		if payload.ServiceName != "worker" || payload.Event != "log_alert" || payload.Line != "FATAL: queue unreachable" {
+			t.Errorf("unexpected payload: %+v", payload)
+		}
+		if strings.Join(payload.Before, ",") != "starting" || strings.Join(payload.After, ",") != "retrying,degraded" {
+			t.Errorf("unexpected context: before %v, after %v", payload.Before, payload.After)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("alert webhook was not sent")
+	}
+}
+
+func TestServiceEventsArePublished(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+	events := m.Events().Subscribe()
+	defer m.Events().Unsubscribe(events)
+
+	job := ServiceConfig{Name: "job", Command: `sh -c "exit 3"`, Type: "oneshot"}
+	m.OnServicesUpdated([]ServiceConfig{job}, nil)
+
+	var types []string
+	timeout := time.After(10 * time.Second)
+	for len(types) < 4 {
+		select {
+		case event := <-events:
+			types = append(types, event.Type)
+			if event.Type == EventExited && (event.ExitCode == nil || *event.ExitCode != 3) {
+				t.Errorf("expected exit code 3, got %+v", event)
+			}
+		case <-timeout:
+			t.Fatalf("missing events, got %v", types)
+		}
+	}
+
+	// The process may exit before or after the configuration is applied
+	got := strings.Join(types, ",")
+	if got != "created,started,config_reloaded,exited" && got != "created,started,exited,config_reloaded" {
+		t.Errorf("unexpected events %s", got)
+	}
+
+	m.OnServicesUpdated(nil, nil)
+	if event := <-events; event.Type != EventRemoved || event.Service != "job" {
+		t.Errorf("expected removed event, got %+v", event)
+	}
+}
+
+func TestScheduledServiceCatchUp(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// The last runs were two days ago, so yesterday's daily runs were missed
+	past := time.Now().AddDate(0, 0, -2)
+	for _, name := range []string{"report", "cleanup"} {
+		h := NewRunHistory(filepath.Join("logs", name+"-runs.jsonl"))
+		if err := h.Append(RunRecord{ID: newRunID(past), Trigger: TriggerCron, StartTime: past, EndTime: past}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+	m.OnServicesUpdated([]ServiceConfig{
+		{Name: "report", Command: "true", Schedule: "0 3 * * *", CatchUp: true},
+		{Name: "cleanup", Command: "true", Schedule: "0 3 * * *"},
+	}, nil)
+
+	report, _ := m.GetService("report")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, total := report.GetRuns(0, 10); total == 2 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if runs, total := report.GetRuns(0, 10); total != 2 || runs[0].Trigger != TriggerCatchUp {
+		t.Errorf("expected a catch-up run, got %+v", runs)
+	}
+
+	// Without catch_up missed runs are skipped
+	cleanup, _ := m.GetService("cleanup")
+	if _, total := cleanup.GetRuns(0, 10); total != 1 || cleanup.IsRunning() {
+		t.Errorf("expected no catch-up run without catch_up, got %d runs", total)
+	}
+}
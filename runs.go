@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	maxRunHistory  = 1000              // Maximum number of run records kept per service
+	triggeredByEnv = "SM_TRIGGERED_BY" // Environment variable naming the service that triggered an on_success/on_failure run
+)
+
+// Run trigger sources
+const (
+	TriggerStartup   = "startup"    // Started automatically by the manager (startup, enable, config change)
+	TriggerManual    = "manual"     // Started via the start/restart API
+	TriggerRestart   = "restart"    // Automatic restart after the process exited
+	TriggerCron      = "cron"       // Started by the cron scheduler
+	TriggerCatchUp   = "catch-up"   // Started because a scheduled run was missed (catch_up)
+	TriggerRunNow    = "run-now"    // Started via the run-now API
+	TriggerWatch     = "watch"      // Started because watched files were created or modified
+	TriggerOnSuccess = "on-success" // Started because a service listing it in on_success succeeded
+	TriggerOnFailure = "on-failure" // Started because a service listing it in on_failure failed
+)
+
+// RunOptions describes how a single run of a service is started
+type RunOptions struct {
+	Trigger string
+	Args    []string          // Extra arguments appended to the configured command (this run only)
+	Env     map[string]string // Environment overrides applied on top of the configured env (this run only)
+}
+
+// RunRecord describes a single execution of a service
+type RunRecord struct {
+	ID          string    `json:"id"`
+	Trigger     string    `json:"trigger"`
+	Args        []string  `json:"args,omitempty"`    // Extra arguments of a parameterised run
+	EnvKeys     []string  `json:"envKeys,omitempty"` // Names of the environment overrides of a parameterised run (values may be secrets)
+	PID         int       `json:"pid"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	ExitCode    int       `json:"exitCode"`
+	Signal      string    `json:"signal,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Duration    float64   `json:"duration"`          // Seconds
+	Stopped     bool      `json:"stopped,omitempty"` // Stopped intentionally by the manager
+	StdoutStart int64     `json:"stdoutStart"`       // Byte offsets of this run in the stdout log file
+	StdoutEnd   int64     `json:"stdoutEnd"`
+	StderrStart int64     `json:"stderrStart"` // Byte offsets of this run in the stderr log file
+	StderrEnd   int64     `json:"stderrEnd"`
+	LogFile     string    `json:"logFile,omitempty"` // Per-run log file (if run logs are enabled)
+}
+
+// envKeys returns the sorted names of environment variables
+func envKeys(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runIDs makes the IDs of runs started within the same millisecond unique
+var runIDs struct {
+	sync.Mutex
+	last string // Millisecond of the most recently returned ID
+	n    int    // Counter of the most recently returned ID
+}
+
+// newRunID returns a sortable, filename-safe run identifier for the given start time. The
+// millisecond is followed by a zero-padded counter of the runs started within it, so IDs
+// (and run log file names) sort by start time.
+func newRunID(start time.Time) string {
+	ms := start.Format("20060102-150405.000")
+
+	runIDs.Lock()
+	defer runIDs.Unlock()
+	if ms == runIDs.last {
+		runIDs.n++
+	} else {
+		runIDs.last, runIDs.n = ms, 0
+	}
+	return fmt.Sprintf("%s-%03d", ms, runIDs.n)
+}
+
+// RunHistory is a durable, append-only store of run records for one service.
+// Records are kept in memory and persisted as JSON lines.
+type RunHistory struct {
+	path    string
+	records []RunRecord // Oldest first
+	mu      sync.RWMutex
+}
+
+// NewRunHistory creates a run history backed by the given file, loading any existing records
+func NewRunHistory(path string) *RunHistory {
+	h := &RunHistory{path: path}
+	h.load()
+	return h
+}
+
+// load reads existing records from disk, skipping lines that cannot be parsed
+func (h *RunHistory) load() {
+	file, err := os.Open(h.path)
+	if err != nil {
+		// File doesn't exist yet, that's okay
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	legacyEnv := false
+	for scanner.Scan() {
+		var rec struct {
+			RunRecord
+			Env map[string]string `json:"env"` // Older records stored the override values
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Env != nil {
+			rec.EnvKeys = envKeys(rec.Env)
+			legacyEnv = true
+		}
+		h.records = append(h.records, rec.RunRecord)
+	}
+	file.Close()
+
+	if len(h.records) > maxRunHistory {
+		removeRunLogs(h.records[:len(h.records)-maxRunHistory])
+		h.records = h.records[len(h.records)-maxRunHistory:]
+	}
+
+	// Remove the values of older records from disk
+	if legacyEnv {
+		if err := h.rewrite(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rewrite run history %s: %v\n", h.path, err)
+		}
+	}
+}
+
+// Append records a finished run and persists it
+func (h *RunHistory) Append(rec RunRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, rec)
+
+	// Compact the file once it has grown well past the limit
+	if len(h.records) > maxRunHistory+maxRunHistory/10 {
+		removeRunLogs(h.records[:len(h.records)-maxRunHistory])
+		h.records = append([]RunRecord{}, h.records[len(h.records)-maxRunHistory:]...)
+		return h.rewrite()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create run history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open run history: %w", err)
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run record: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write run record: %w", err)
+	}
+
+	return nil
+}
+
+// rewrite atomically replaces the history file with the in-memory records
+// Caller must hold the lock
+func (h *RunHistory) rewrite() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create run history directory: %w", err)
+	}
+
+	tempPath := h.path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, rec := range h.records {
+		if err := enc.Encode(rec); err != nil {
+			file.Close()
+			os.Remove(tempPath)
+			return fmt.Errorf("failed to marshal run record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	file.Close()
+
+	if err := os.Rename(tempPath, h.path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	return nil
+}
+
+// List returns up to limit records starting at offset, newest first, and the total record count
+func (h *RunHistory) List(offset, limit int) ([]RunRecord, int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	total := len(h.records)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= total || limit <= 0 {
+		return []RunRecord{}, total
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	result := make([]RunRecord, 0, end-offset)
+	for i := offset; i < end; i++ {
+		result = append(result, h.records[total-1-i])
+	}
+	return result, total
+}
+
+// Get returns the record with the given run ID
+func (h *RunHistory) Get(id string) (RunRecord, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].ID == id {
+			return h.records[i], true
+		}
+	}
+	return RunRecord{}, false
+}
+
+// Last returns the most recent record
+func (h *RunHistory) Last() (RunRecord, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.records) == 0 {
+		return RunRecord{}, false
+	}
+	return h.records[len(h.records)-1], true
+}
+
+// PruneRunLogs deletes the per-run log files of all but the newest keep runs (0 = unlimited)
+// and of runs that ended more than maxAge ago (0 = unlimited). Only files recorded in the
+// history are deleted.
+func (h *RunHistory) PruneRunLogs(keep int, maxAge time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var firstErr error
+	count := 0
+	for i := len(h.records) - 1; i >= 0; i-- {
+		rec := &h.records[i]
+		if rec.LogFile == "" {
+			continue
+		}
+		count++
+		if (keep <= 0 || count <= keep) && (maxAge <= 0 || time.Since(rec.EndTime) <= maxAge) {
+			continue
+		}
+		if err := os.Remove(rec.LogFile); err != nil && !os.IsNotExist(err) {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		rec.LogFile = "" // Deleted (the file on disk still names it until the next rewrite)
+	}
+	return firstErr
+}
+
+// removeRunLogs deletes the per-run log files of records dropped from the history
+func removeRunLogs(records []RunRecord) {
+	for _, rec := range records {
+		if rec.LogFile == "" {
+			continue
+		}
+		if err := os.Remove(rec.LogFile); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Failed to remove run log %s: %v\n", rec.LogFile, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHistory_AppendAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-runs.jsonl")
+
+	h := NewRunHistory(path)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		rec := RunRecord{
+			ID:        newRunID(start.Add(time.Duration(i) * time.Minute)),
+			Trigger:   TriggerCron,
+			StartTime: start.Add(time.Duration(i) * time.Minute),
+			ExitCode:  i,
+		}
+		if err := h.Append(rec); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	// Reload from disk
+	reloaded := NewRunHistory(path)
+	last, ok := reloaded.Last()
+	if !ok {
+		t.Fatal("Expected a last record after reload")
+	}
+	if last.ExitCode != 2 {
+		t.Errorf("Expected last exit code 2, got %d", last.ExitCode)
+	}
+	if last.Trigger != TriggerCron {
+		t.Errorf("Expected trigger %q, got %q", TriggerCron, last.Trigger)
+	}
+}
+
+func TestRunHistory_ListNewestFirstWithPagination(t *testing.T) {
+	h := NewRunHistory(filepath.Join(t.TempDir(), "svc-runs.jsonl"))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		h.Append(RunRecord{ID: newRunID(start.Add(time.Duration(i) * time.Second)), ExitCode: i})
+	}
+
+	runs, total := h.List(0, 2)
+	if total != 5 {
+		t.Fatalf("Expected total 5, got %d", total)
+	}
+	if len(runs) != 2 || runs[0].ExitCode != 4 || runs[1].ExitCode != 3 {
+		t.Errorf("Expected newest two runs (4, 3), got %+v", runs)
+	}
+
+	runs, _ = h.List(4, 10)
+	if len(runs) != 1 || runs[0].ExitCode != 0 {
+		t.Errorf("Expected oldest run on last page, got %+v", runs)
+	}
+
+	runs, _ = h.List(10, 10)
+	if len(runs) != 0 {
+		t.Errorf("Expected empty page past the end, got %d runs", len(runs))
+	}
+}
+
+func TestRunHistory_SkipsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-runs.jsonl")
+	content := `{"id":"a","exitCode":1}
+not json
+{"id":"b","exitCode":0}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	h := NewRunHistory(path)
+	runs, total := h.List(0, 10)
+	if total != 2 {
+		t.Fatalf("Expected 2 valid records, got %d", total)
+	}
+	if runs[0].ID != "b" {
+		t.Errorf("Expected newest record b, got %s", runs[0].ID)
+	}
+}
+
+// appendRunsWithLogs records runs that ended at the given times, each with a run log file
+func appendRunsWithLogs(t *testing.T, h *RunHistory, dir string, ends ...time.Time) []string {
+	t.Helper()
+	var paths []string
+	for _, end := range ends {
+		id := newRunID(end)
+		path := filepath.Join(dir, id+".log")
+		if err := os.WriteFile(path, []byte("output\n"), 0644); err != nil {
+			t.Fatalf("Failed to write run log: %v", err)
+		}
+		if err := h.Append(RunRecord{ID: id, StartTime: end, EndTime: end, LogFile: path}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestRunHistory_PruneRunLogsKeepNewest(t *testing.T) {
+	dir := t.TempDir()
+	h := NewRunHistory(filepath.Join(dir, "svc-runs.jsonl"))
+	end := time.Now()
+	paths := appendRunsWithLogs(t, h, dir, end.Add(-2*time.Minute), end.Add(-time.Minute), end)
+
+	// Files the history didn't create are never deleted
+	other := filepath.Join(dir, "notes.log")
+	os.WriteFile(other, []byte("keep me\n"), 0644)
+
+	if err := h.PruneRunLogs(2, 0); err != nil {
+		t.Fatalf("PruneRunLogs failed: %v", err)
+	}
+
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected oldest run log to be removed")
+	}
+	for _, path := range append(paths[1:], other) {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+	if runs, _ := h.List(0, 10); runs[2].LogFile != "" || runs[1].LogFile == "" {
+		t.Errorf("Expected only the oldest run to lose its log file, got %+v", runs)
+	}
+}
+
+func TestRunHistory_PruneRunLogsMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	h := NewRunHistory(filepath.Join(dir, "svc-runs.jsonl"))
+	paths := appendRunsWithLogs(t, h, dir, time.Now().Add(-48*time.Hour), time.Now())
+
+	if err := h.PruneRunLogs(0, 24*time.Hour); err != nil {
+		t.Fatalf("PruneRunLogs failed: %v", err)
+	}
+
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected old run log to be removed")
+	}
+	if _, err := os.Stat(paths[1]); err != nil {
+		t.Errorf("Expected recent run log to be kept: %v", err)
+	}
+}
+
+func TestRunHistory_CompactionRemovesRunLogs(t *testing.T) {
+	dir := t.TempDir()
+	h := NewRunHistory(filepath.Join(dir, "svc-runs.jsonl"))
+	start := time.Date(2032, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := appendRunsWithLogs(t, h, dir, start)[0]
+	for i := 1; i <= maxRunHistory+maxRunHistory/10; i++ {
+		h.Append(RunRecord{ID: newRunID(start.Add(time.Duration(i) * time.Second))})
+	}
+
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("Expected the run log of a compacted record to be removed")
+	}
+}
+
+func TestRunHistory_EnvValuesNotStored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc-runs.jsonl")
+
+	// Older versions stored the values of environment overrides
+	legacy := `{"id":"20240102-030405.000","trigger":"run-now","env":{"TOKEN":"secret","DEBUG":"1"}}` + "\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewRunHistory(path)
+	last, _ := h.Last()
+	if len(last.EnvKeys) != 2 || last.EnvKeys[0] != "DEBUG" || last.EnvKeys[1] != "TOKEN" {
+		t.Errorf("Expected the override names, got %v", last.EnvKeys)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("Expected the values to be removed from disk, got %s", data)
+	}
+}
+
+func TestNewRunID_UniqueWithinMillisecond(t *testing.T) {
+	start := time.Date(2031, 1, 2, 3, 4, 5, 0, time.UTC)
+	first, second, third := newRunID(start), newRunID(start), newRunID(start)
+	if first != "20310102-030405.000-000" || second != "20310102-030405.000-001" || third != "20310102-030405.000-002" {
+		t.Errorf("Expected unique IDs, got %s %s %s", first, second, third)
+	}
+	// Run log names sort in start order, also across milliseconds
+	names := []string{first + ".log", second + ".log", third + ".log", newRunID(start.Add(time.Millisecond)) + ".log"}
+	if !slices.IsSorted(names) {
+		t.Errorf("Expected run log names to sort by start time: %v", names)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"compress/gzip"
 	"embed"
 	"encoding/base64"
 	"encoding/json"
@@ -11,8 +12,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/shlex"
 	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
 )
@@ -31,6 +36,17 @@ type ServiceRequest struct {
 	Env      map[string]string `json:"env"`     // For backwards compatibility
 	Enabled  *bool             `json:"enabled"`
 	Schedule string            `json:"schedule"`
+	Type     string            `json:"type"`
+	After    []string          `json:"after"`
+	Labels   []string          `json:"labels"`
+}
+
+// RunNowRequest represents the optional JSON body of a run-now request
+type RunNowRequest struct {
+	Args    []string          `json:"args"`     // Extra arguments appended to the command
+	ArgsRaw string            `json:"args_raw"` // Extra arguments as a shell-style string
+	Env     map[string]string `json:"env"`      // Environment overrides
+	EnvRaw  string            `json:"env_raw"`  // Environment overrides in dotenv format
 }
 
 // Server represents the web server
@@ -40,23 +56,12 @@ type Server struct {
 	host           string
 	port           int
 	upgrader       websocket.Upgrader
-	username       string // BasicAuth username (empty = no username required)
-	password       string // BasicAuth password (empty = no auth)
+	users          map[string]*User // Accounts by name (nil = no auth)
 }
 
 // New creates a new web server
 func NewServer(serviceManager *ServiceManager, configManager *ConfigManager) *Server {
-	// Parse authorization config once
-	var username, password string
 	config := serviceManager.GetGlobalConfig()
-	if config.Authorization != "" {
-		if idx := strings.Index(config.Authorization, ":"); idx > 0 {
-			username = config.Authorization[:idx]
-			password = config.Authorization[idx+1:]
-		} else {
-			password = config.Authorization
-		}
-	}
 
 	return &Server{
 		serviceManager: serviceManager,
@@ -64,121 +69,300 @@ func NewServer(serviceManager *ServiceManager, configManager *ConfigManager) *Se
 		host:           config.Host,
 		port:           config.Port,
 		upgrader:       websocket.Upgrader{},
-		username:       username,
-		password:       password,
+		users:          loadUsers(config),
 	}
 }
 
-// basicAuthMiddleware wraps the entire handler with BasicAuth authentication
+// basicAuthMiddleware wraps the entire handler with BasicAuth authentication and attaches the
+// authenticated user to the request
 func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// If no password configured, allow all requests
-		if s.password == "" {
+		// If no users configured, allow all requests
+		if s.users == nil {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		expectedCredentials := base64.StdEncoding.EncodeToString(
-			[]byte(s.username + ":" + s.password))
-
-		authenticated := false
+		var user *User
 
 		// Check cookie first
 		if cookie, err := r.Cookie(authCookieName); err == nil {
-			if cookie.Value == expectedCredentials {
-				authenticated = true
+			if decoded, err := base64.StdEncoding.DecodeString(cookie.Value); err == nil {
+				if username, password, ok := strings.Cut(string(decoded), ":"); ok {
+					user = s.authenticate(username, password)
+				}
 			}
 		}
 
 		// Fall back to Basic Auth if cookie not valid
-		if !authenticated {
+		if user == nil {
 			username, password, ok := r.BasicAuth()
-			if !ok || username != s.username || password != s.password {
+			if ok {
+				user = s.authenticate(username, password)
+			}
+			if user == nil {
 				w.Header().Set("WWW-Authenticate", `Basic realm="Service Manager"`)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
-			authenticated = true
 		}
 
 		// Set/renew cookie on every successful authentication (sliding expiration)
 		http.SetCookie(w, &http.Cookie{
 			Name:     authCookieName,
-			Value:    expectedCredentials,
+			Value:    base64.StdEncoding.EncodeToString([]byte(user.Name + ":" + user.password)),
 			Path:     "/",
 			MaxAge:   30 * 24 * 60 * 60, // 30 days
 			HttpOnly: true,
 			SameSite: http.SameSiteStrictMode,
 		})
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withUser(r, user))
 	})
 }
 
 // Serve starts the web server using the provided listener
 // This allows the caller to acquire the port first (for exclusive access)
 func (s *Server) Serve(listener net.Listener) error {
+	fmt.Printf("Starting web server on http://%s\n", listener.Addr().String())
+	return http.Serve(listener, s.handler())
+}
+
+// handler returns the routes of the API and the static files, behind authentication
+func (s *Server) handler() http.Handler {
 	mux := http.NewServeMux()
 
-	// API routes with pattern matching
-	mux.HandleFunc("GET /api/services", s.listServices)
-	mux.HandleFunc("POST /api/services", s.createService)
-	mux.HandleFunc("GET /api/services/{name}", s.getService)
-	mux.HandleFunc("PUT /api/services/{name}", s.updateService)
-	mux.HandleFunc("DELETE /api/services/{name}", s.deleteService)
-	mux.HandleFunc("GET /api/services/{name}/dotenv", s.getDotenv)
-	mux.HandleFunc("POST /api/services/{name}/start", s.startService)
-	mux.HandleFunc("POST /api/services/{name}/stop", s.stopService)
-	mux.HandleFunc("POST /api/services/{name}/restart", s.restartService)
-	mux.HandleFunc("POST /api/services/{name}/enable", s.enableService)
-	mux.HandleFunc("POST /api/services/{name}/disable", s.disableService)
-	mux.HandleFunc("POST /api/services/{name}/run-now", s.runNowService)
-	mux.HandleFunc("GET /api/services/{name}/logs/{stream}", s.streamLogs)
+	// API routes with pattern matching, each requiring a role (and access to {name})
+	mux.HandleFunc("GET /api/me", s.getMe)
+	mux.HandleFunc("GET /api/services", s.require(RoleViewer, s.listServices))
+	mux.HandleFunc("POST /api/services", s.require(RoleAdmin, s.createService))
+	mux.HandleFunc("GET /api/services/{name}", s.require(RoleViewer, s.getService))
+	mux.HandleFunc("PUT /api/services/{name}", s.require(RoleAdmin, s.updateService))
+	mux.HandleFunc("DELETE /api/services/{name}", s.require(RoleAdmin, s.deleteService))
+	mux.HandleFunc("GET /api/services/{name}/dotenv", s.require(RoleAdmin, s.getDotenv))
+	mux.HandleFunc("POST /api/services/{name}/start", s.require(RoleOperator, s.startService))
+	mux.HandleFunc("POST /api/services/{name}/stop", s.require(RoleOperator, s.stopService))
+	mux.HandleFunc("POST /api/services/{name}/restart", s.require(RoleOperator, s.restartService))
+	mux.HandleFunc("POST /api/services/{name}/enable", s.require(RoleOperator, s.enableService))
+	mux.HandleFunc("POST /api/services/{name}/disable", s.require(RoleOperator, s.disableService))
+	mux.HandleFunc("POST /api/services/{name}/run-now", s.require(RoleOperator, s.runNowService))
+	mux.HandleFunc("GET /api/services/{name}/runs", s.require(RoleViewer, s.listRuns))
+	mux.HandleFunc("GET /api/services/{name}/runs/{id}/log", s.require(RoleViewer, s.getRunLog))
+	mux.HandleFunc("GET /api/services/{name}/logs/search", s.require(RoleViewer, s.searchLogs))
+	mux.HandleFunc("GET /api/services/{name}/logs/{stream}", s.require(RoleViewer, s.streamLogs))
+	mux.HandleFunc("GET /api/services/{name}/logs/{stream}/range", s.require(RoleViewer, s.getLogRange))
+	mux.HandleFunc("GET /api/services/{name}/logs/{stream}/download", s.require(RoleViewer, s.downloadLog))
+	mux.HandleFunc("DELETE /api/services/{name}/logs", s.require(RoleOperator, s.clearLogs))
+	mux.HandleFunc("DELETE /api/services/{name}/logs/{stream}", s.require(RoleOperator, s.clearLogs))
+	mux.HandleFunc("GET /api/logs/archive", s.require(RoleAdmin, s.downloadLogArchive))
+	mux.HandleFunc("GET /api/status/events", s.require(RoleViewer, s.streamStatus))
+	mux.HandleFunc("GET /api/events", s.require(RoleViewer, s.streamEvents))
+	mux.HandleFunc("GET /api/ws", s.require(RoleViewer, s.streamMux))
+	mux.HandleFunc("GET /api/audit", s.require(RoleAdmin, s.listAudit))
 
 	// Static files (catch-all)
 	mux.HandleFunc("GET /{path...}", s.handleStatic)
 
 	// Wrap entire mux with auth middleware
-	handler := s.basicAuthMiddleware(mux)
-
-	fmt.Printf("Starting web server on http://%s\n", listener.Addr().String())
-	return http.Serve(listener, handler)
+	return s.basicAuthMiddleware(mux)
 }
 
 // listServices returns all services with their status
 func (s *Server) listServices(w http.ResponseWriter, r *http.Request) {
-	services := s.serviceManager.GetAllServices()
-
-	statuses := make([]interface{}, len(services))
-	for i, svc := range services {
-		status := svc.GetStatus()
-		item := map[string]interface{}{
-			"name":         status.Name,
-			"running":      status.Running,
-			"pid":          status.PID,
-			"uptime":       status.Uptime.Seconds(),
-			"restarts":     status.Restarts,
-			"enabled":      svc.Config.IsEnabled(),
-			"schedule":     svc.Config.Schedule,
-			"lastRunTime":  status.LastRunTime,
-			"lastExitCode": status.LastExitCode,
-			"lastDuration": status.LastDuration.Seconds(),
-		}
-
-		// Add next run time for scheduled services
-		if svc.Config.IsScheduled() {
-			if nextRun, ok := s.serviceManager.GetNextRunTime(status.Name); ok {
-				item["nextRunTime"] = nextRun
-			}
+	user := requestUser(r)
+	statuses := make([]interface{}, 0)
+	for _, svc := range s.serviceManager.GetAllServices() {
+		if user.CanAccess(svc.Config) {
+			statuses = append(statuses, s.serviceSummary(svc))
 		}
-
-		statuses[i] = item
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(statuses)
 }
 
+// serviceSummary returns the status of a service as listed by the API
+func (s *Server) serviceSummary(svc *Service) map[string]interface{} {
+	status := svc.GetStatus()
+	item := map[string]interface{}{
+		"name":            status.Name,
+		"running":         status.Running,
+		"pid":             status.PID,
+		"uptime":          status.Uptime.Seconds(),
+		"restarts":        status.Restarts,
+		"enabled":         svc.Config.IsEnabled(),
+		"schedule":        svc.Config.Schedule,
+		"type":            svc.Config.Type,
+		"pending":         s.serviceManager.IsPending(status.Name),
+		"lastRunTime":     status.LastRunTime,
+		"lastExitCode":    status.LastExitCode,
+		"lastDuration":    status.LastDuration.Seconds(),
+		"errorsPerMinute": svc.ErrorsPerMinute(),
+	}
+
+	// Add next run time for scheduled services
+	if svc.Config.IsScheduled() {
+		if nextRun, ok := s.serviceManager.GetNextRunTime(status.Name); ok {
+			item["nextRunTime"] = nextRun
+		}
+	}
+	return item
+}
+
+// streamStatus sends the status of all services (or one with ?service=) as Server-Sent Events:
+// a "status" event for every service when connecting and whenever its status changes, and a
+// "removed" event when a service is deleted
+func (s *Server) streamStatus(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	only := r.URL.Query().Get("service")
+	if only != "" {
+		svc, err := s.serviceManager.GetService(only)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if !user.CanAccess(svc.Config) {
+			http.Error(w, fmt.Sprintf("Forbidden: no access to service %s", only), http.StatusForbidden)
+			return
+		}
+	}
+
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Check on every event, and periodically for changes without an event (e.g. error rate)
+	events := s.serviceManager.Events().Subscribe()
+	defer s.serviceManager.Events().Unsubscribe(events)
+	ticker := time.NewTicker(statusStreamInterval)
+	defer ticker.Stop()
+	lastSent := time.Now()
+	sent := make(map[string]string) // Last sent status per service, without the uptime
+	for {
+		current := make(map[string]bool)
+		for _, svc := range s.serviceManager.GetAllServices() {
+			if (only != "" && svc.Config.Name != only) || !user.CanAccess(svc.Config) {
+				continue
+			}
+			item := s.serviceSummary(svc)
+			name := svc.Config.Name
+			current[name] = true
+
+			// The uptime alone changes every second and doesn't count as a change
+			uptime := item["uptime"]
+			delete(item, "uptime")
+			key, _ := json.Marshal(item)
+			if sent[name] == string(key) {
+				continue
+			}
+			item["uptime"] = uptime
+			if err := sse.JSONEvent("", "status", item); err != nil {
+				return
+			}
+			sent[name] = string(key)
+			lastSent = time.Now()
+		}
+		for name := range sent {
+			if !current[name] {
+				if err := sse.JSONEvent("", "removed", map[string]string{"name": name}); err != nil {
+					return
+				}
+				delete(sent, name)
+				lastSent = time.Now()
+			}
+		}
+
+		if time.Since(lastSent) >= sseKeepAlive {
+			if err := sse.KeepAlive(); err != nil {
+				return
+			}
+			lastSent = time.Now()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		case <-events:
+		}
+	}
+}
+
+// EventMessage is an event as sent by GET /api/events, with the current status of its service
+type EventMessage struct {
+	Event
+	Status map[string]interface{} `json:"status,omitempty"` // Same fields as GET /api/services
+}
+
+// eventMessage adds the current status of the event's service to an event
+func (s *Server) eventMessage(event Event) EventMessage {
+	msg := EventMessage{Event: event}
+	if event.Service != "" {
+		if svc, err := s.serviceManager.GetService(event.Service); err == nil {
+			msg.Status = s.serviceSummary(svc)
+		}
+	}
+	return msg
+}
+
+// streamEvents sends the events of the event bus via WebSocket, or as Server-Sent Events for
+// other requests. Events of a service that still exists carry its current status.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	var sse *sseWriter
+	var conn *websocket.Conn
+	if websocket.IsWebSocketUpgrade(r) {
+		var err error
+		conn, err = s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+	} else {
+		var err error
+		sse, err = newSSEWriter(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	user := requestUser(r)
+	events := s.serviceManager.Events().Subscribe()
+	defer s.serviceManager.Events().Unsubscribe(events)
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if sse != nil {
+				err = sse.KeepAlive()
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Service != "" && !s.canAccessService(user, event.Service) {
+				continue
+			}
+			msg := s.eventMessage(event)
+			if sse != nil {
+				err = sse.JSONEvent("", "", msg)
+			} else {
+				err = conn.WriteJSON(msg)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 // getService returns a specific service with config and status
 func (s *Server) getService(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
@@ -190,19 +374,32 @@ func (s *Server) getService(w http.ResponseWriter, r *http.Request) {
 
 	status := svc.GetStatus()
 	response := map[string]any{
-		"name":         svc.Config.Name,
-		"command":      svc.Config.Command,
-		"workdir":      svc.Config.Workdir,
-		"env":          svc.Config.Env,
-		"running":      status.Running,
-		"pid":          status.PID,
-		"uptime":       status.Uptime.Seconds(),
-		"restarts":     status.Restarts,
-		"enabled":      svc.Config.IsEnabled(),
-		"schedule":     svc.Config.Schedule,
-		"lastRunTime":  status.LastRunTime,
-		"lastExitCode": status.LastExitCode,
-		"lastDuration": status.LastDuration.Seconds(),
+		"name":            svc.Config.Name,
+		"command":         svc.Config.Command,
+		"workdir":         svc.Config.Workdir,
+		"env":             svc.Config.Env,
+		"running":         status.Running,
+		"pid":             status.PID,
+		"uptime":          status.Uptime.Seconds(),
+		"restarts":        status.Restarts,
+		"enabled":         svc.Config.IsEnabled(),
+		"schedule":        svc.Config.Schedule,
+		"type":            svc.Config.Type,
+		"after":           svc.Config.After,
+		"onSuccess":       svc.Config.OnSuccess,
+		"onFailure":       svc.Config.OnFailure,
+		"pending":         s.serviceManager.IsPending(svc.Config.Name),
+		"lastRunTime":     status.LastRunTime,
+		"lastExitCode":    status.LastExitCode,
+		"lastDuration":    status.LastDuration.Seconds(),
+		"errorsPerMinute": svc.ErrorsPerMinute(),
+		"logFormat":       svc.Config.LogFormat,
+		"labels":          svc.Config.Labels,
+	}
+
+	// The environment may contain secrets, only admins can see (and edit) it
+	if !requestUser(r).HasRole(RoleAdmin) {
+		delete(response, "env")
 	}
 
 	// Add next run time for scheduled services
@@ -212,6 +409,11 @@ func (s *Server) getService(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Add watched paths for jobs with a file-change trigger
+	if svc.Config.IsWatched() {
+		response["watch"] = svc.Config.Watch.Paths
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -229,6 +431,11 @@ func (s *Server) createService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !isValidServiceType(req.Type) {
+		http.Error(w, fmt.Sprintf("Invalid service type %q", req.Type), http.StatusBadRequest)
+		return
+	}
+
 	// Parse environment variables
 	var envMap map[string]string
 	if req.EnvRaw != "" {
@@ -251,6 +458,15 @@ func (s *Server) createService(w http.ResponseWriter, r *http.Request) {
 		Env:      envMap,
 		Enabled:  req.Enabled,
 		Schedule: req.Schedule,
+		Type:     req.Type,
+		After:    req.After,
+		Labels:   req.Labels,
+	}
+
+	// Users limited to some services can only create services they can access
+	if !requestUser(r).CanAccess(cfg) {
+		http.Error(w, fmt.Sprintf("Forbidden: no access to service %s", cfg.Name), http.StatusForbidden)
+		return
 	}
 
 	if err := s.configManager.AddService(cfg); err != nil {
@@ -262,6 +478,8 @@ func (s *Server) createService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.audit(r, AuditEntry{Action: AuditCreate, Service: cfg.Name, Changes: configDiff(nil, &cfg)})
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{"status": "created"})
 }
@@ -280,6 +498,11 @@ func (s *Server) updateService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !isValidServiceType(req.Type) {
+		http.Error(w, fmt.Sprintf("Invalid service type %q", req.Type), http.StatusBadRequest)
+		return
+	}
+
 	// Parse environment variables
 	var envMap map[string]string
 	if req.EnvRaw != "" {
@@ -295,13 +518,31 @@ func (s *Server) updateService(w http.ResponseWriter, r *http.Request) {
 		envMap = req.Env
 	}
 
-	cfg := ServiceConfig{
-		Name:     name, // Use name from URL
-		Command:  req.Command,
-		Workdir:  req.Workdir,
-		Env:      envMap,
-		Enabled:  req.Enabled,
-		Schedule: req.Schedule,
+	// Start from the existing config so YAML-only settings are preserved
+	cfg, _, exists := s.configManager.GetService(name)
+	if !exists {
+		http.Error(w, fmt.Sprintf("service %s not found", name), http.StatusNotFound)
+		return
+	}
+	before := cfg
+	cfg.Name = name // Use name from URL
+	cfg.Command = req.Command
+	cfg.Workdir = req.Workdir
+	cfg.Env = envMap
+	cfg.Enabled = req.Enabled
+	cfg.Schedule = req.Schedule
+	cfg.Type = req.Type
+	if req.After != nil {
+		cfg.After = req.After
+	}
+	if req.Labels != nil {
+		cfg.Labels = req.Labels
+	}
+
+	// Users limited to some services can't relabel a service out of their reach
+	if !requestUser(r).CanAccess(cfg) {
+		http.Error(w, fmt.Sprintf("Forbidden: no access to service %s with labels %v", name, cfg.Labels), http.StatusForbidden)
+		return
 	}
 
 	if err := s.configManager.UpdateService(name, cfg); err != nil {
@@ -313,6 +554,8 @@ func (s *Server) updateService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.audit(r, AuditEntry{Action: AuditUpdate, Service: name, Changes: configDiff(&before, &cfg)})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
 }
@@ -320,6 +563,7 @@ func (s *Server) updateService(w http.ResponseWriter, r *http.Request) {
 // deleteService deletes a service
 func (s *Server) deleteService(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+	before, _, _ := s.configManager.GetService(name)
 	if err := s.configManager.DeleteService(name); err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -329,10 +573,43 @@ func (s *Server) deleteService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.audit(r, AuditEntry{Action: AuditDelete, Service: name, Changes: configDiff(&before, nil)})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
 }
 
+// audit records an API action with the requesting user and address
+func (s *Server) audit(r *http.Request, entry AuditEntry) {
+	entry.Actor = s.requestActor(r)
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		entry.IP = host
+	}
+	s.serviceManager.AuditLog().Record(entry)
+}
+
+// auditEnabled records enabling or disabling a service
+func (s *Server) auditEnabled(r *http.Request, action string, before ServiceConfig, enabled bool) {
+	after := before
+	after.Enabled = &enabled
+	s.audit(r, AuditEntry{Action: action, Service: before.Name, Changes: configDiff(&before, &after)})
+}
+
+// requestActor returns the user that made an authenticated request
+func (s *Server) requestActor(r *http.Request) string {
+	if name := requestUser(r).Name; name != "" {
+		return name
+	}
+	return "admin" // Password-only authorization
+}
+
+// getMe returns the authenticated user and role, so the UI can hide actions it can't perform
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"name": s.requestActor(r), "role": user.Role})
+}
+
 // getDotenv checks for .env file in service's working directory
 func (s *Server) getDotenv(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
@@ -384,6 +661,7 @@ func (s *Server) startService(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, AuditEntry{Action: AuditStart, Service: name})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
@@ -396,6 +674,7 @@ func (s *Server) stopService(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, AuditEntry{Action: AuditStop, Service: name})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "stopped"})
@@ -408,6 +687,7 @@ func (s *Server) restartService(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, AuditEntry{Action: AuditRestart, Service: name})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "restarted"})
@@ -416,10 +696,12 @@ func (s *Server) restartService(w http.ResponseWriter, r *http.Request) {
 // enableService enables a service
 func (s *Server) enableService(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+	before, _, _ := s.configManager.GetService(name)
 	if err := s.configManager.SetServiceEnabled(name, true); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.auditEnabled(r, AuditEnable, before, true)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "enabled"})
@@ -428,53 +710,199 @@ func (s *Server) enableService(w http.ResponseWriter, r *http.Request) {
 // disableService disables a service
 func (s *Server) disableService(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+	before, _, _ := s.configManager.GetService(name)
 	if err := s.configManager.SetServiceEnabled(name, false); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.auditEnabled(r, AuditDisable, before, false)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "disabled"})
 }
 
-// runNowService runs a scheduled service immediately
+// runNowService runs a scheduled, oneshot or manual service immediately.
+// An optional JSON body supplies extra arguments and environment overrides for this run only.
 func (s *Server) runNowService(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+
+	var req RunNowRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts := RunOptions{Trigger: TriggerRunNow, Args: req.Args, Env: req.Env}
+
+	// Parse raw arguments (shell-style quoting)
+	if strings.TrimSpace(req.ArgsRaw) != "" {
+		parsed, err := shlex.Split(req.ArgsRaw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid arguments: %v", err), http.StatusBadRequest)
+			return
+		}
+		opts.Args = append(opts.Args, parsed...)
+	}
+
+	// Parse raw environment overrides (dotenv format)
+	if req.EnvRaw != "" {
+		parsed, err := godotenv.Unmarshal(req.EnvRaw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid environment variable format: %v", err), http.StatusBadRequest)
+			return
+		}
+		if opts.Env == nil {
+			opts.Env = make(map[string]string)
+		}
+		for k, v := range parsed {
+			opts.Env[k] = v
+		}
+	}
+
+	// Extra arguments and environment overrides can run arbitrary commands (e.g. LD_PRELOAD),
+	// operators can only run the configured command
+	if (len(opts.Args) > 0 || len(opts.Env) > 0) && !requestUser(r).HasRole(RoleAdmin) {
+		http.Error(w, fmt.Sprintf("Forbidden: arguments and environment overrides require the %s role", RoleAdmin), http.StatusForbidden)
+		return
+	}
+
+	if err := s.serviceManager.RunService(name, opts); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			status = http.StatusNotFound
+		case strings.Contains(err.Error(), "already running"):
+			status = http.StatusConflict
+		case strings.Contains(err.Error(), "cannot be run"):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	var details []string
+	if len(opts.Args) > 0 {
+		details = append(details, "args: "+strings.Join(opts.Args, " "))
+	}
+	if len(opts.Env) > 0 {
+		details = append(details, "env: "+strings.Join(envKeys(opts.Env), ", "))
+	}
+	s.audit(r, AuditEntry{Action: AuditRunNow, Service: name, Details: strings.Join(details, "; ")})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+}
+
+// listRuns returns the run history of a service (newest first) with pagination
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
 	svc, err := s.serviceManager.GetService(name)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	// Check if it's a scheduled service
-	if !svc.Config.IsScheduled() {
-		http.Error(w, "Service is not a scheduled service", http.StatusBadRequest)
+	offset, limit := 0, 50
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			http.Error(w, "Invalid limit (must be 1-500)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	runs, total := svc.GetRuns(offset, limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"runs":   runs,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// searchLogs searches the full on-disk log history of a service (including rotated files).
+// Query parameters: q (text, or a regular expression with regex=true), stream, since, until,
+// context (lines around each match) and limit.
+func (s *Server) searchLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	svc, err := s.serviceManager.GetService(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	// Check if already running (overlap prevention)
-	if svc.IsRunning() {
-		http.Error(w, "Service is already running", http.StatusConflict)
+	params := r.URL.Query()
+	q := params.Get("q")
+	if q == "" {
+		http.Error(w, "Query parameter q is required", http.StatusBadRequest)
+		return
+	}
+	if params.Get("regex") != "true" {
+		q = regexp.QuoteMeta(q)
+	}
+	pattern, err := regexp.Compile(q)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid regular expression: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Start the service
-	if err := svc.Start(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	query := LogSearchQuery{Pattern: pattern, Stream: params.Get("stream"), Limit: defaultSearchLimit}
+	if query.Stream != "" && query.Stream != "stdout" && query.Stream != "stderr" {
+		http.Error(w, "Stream must be stdout or stderr", http.StatusBadRequest)
+		return
+	}
+	query.Since, query.Until, err = parseTimeRange(params.Get("since"), params.Get("until"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	query.MinLevel, err = parseLogLevelParam(params.Get("level"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := params.Get("context"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSearchContext {
+			http.Error(w, fmt.Sprintf("Invalid context (must be 0-%d)", maxSearchContext), http.StatusBadRequest)
+			return
+		}
+		query.Context = n
+	}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (must be 1-%d)", maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+	json.NewEncoder(w).Encode(svc.SearchLogs(query))
 }
 
-// streamLogs streams logs via WebSocket
-func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request) {
+// getLogRange reads historical lines of a log beyond the in-memory buffer.
+// With ?offset=N&limit=BYTES whole lines are read forward from a virtual byte offset,
+// otherwise ?lines=N&before=OFFSET reads the N lines before an offset (default: the end).
+func (s *Server) getLogRange(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 	stream := r.PathValue("stream")
 
-	if stream != "stdout" && stream != "stderr" {
-		http.Error(w, "Stream must be stdout or stderr", http.StatusBadRequest)
+	if stream != "stdout" && stream != "stderr" && stream != "combined" {
+		http.Error(w, "Stream must be stdout, stderr or combined", http.StatusBadRequest)
 		return
 	}
 
@@ -484,47 +912,546 @@ func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Upgrade to WebSocket
-	conn, err := s.upgrader.Upgrade(w, r, nil)
+	params := r.URL.Query()
+	minLevel, err := parseLogLevelParam(params.Get("level"))
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer conn.Close()
 
-	// Send historical logs
-	var historyBytes []byte
-	switch stream {
-	case "stdout":
-		historyBytes = svc.GetStdoutBuffer()
-	case "stderr":
-		historyBytes = svc.GetStderrBuffer()
-	default:
-		http.Error(w, "Stream must be stdout or stderr", http.StatusNotFound)
+	var logRange LogRange
+	if v := params.Get("offset"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		limit := int64(defaultRangeBytes)
+		if v := params.Get("limit"); v != "" {
+			limit, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || limit <= 0 || limit > maxRangeBytes {
+				http.Error(w, fmt.Sprintf("Invalid limit (must be 1-%d)", maxRangeBytes), http.StatusBadRequest)
+				return
+			}
+		}
+		logRange, err = svc.ReadLogRange(stream, offset, limit)
+	} else {
+		lines := defaultRangeLines
+		if v := params.Get("lines"); v != "" {
+			lines, err = strconv.Atoi(v)
+			if err != nil || lines <= 0 || lines > maxRangeLines {
+				http.Error(w, fmt.Sprintf("Invalid lines (must be 1-%d)", maxRangeLines), http.StatusBadRequest)
+				return
+			}
+		}
+		before := int64(-1)
+		if v := params.Get("before"); v != "" {
+			before, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || before < 0 {
+				http.Error(w, "Invalid before offset", http.StatusBadRequest)
+				return
+			}
+		}
+		logRange, err = svc.ReadLogTail(stream, before, lines)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	if len(historyBytes) > 0 {
-		if err := conn.WriteMessage(websocket.TextMessage, historyBytes); err != nil {
+	// Start/end still describe the whole range read, so paging continues from them
+	if minLevel > 0 {
+		filtered := []LogLine{}
+		for _, line := range logRange.Lines {
+			if line.hasMinLevel(minLevel) {
+				filtered = append(filtered, line)
+			}
+		}
+		logRange.Lines = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logRange)
+}
+
+// downloadLog streams the full log of a stream (including rotated files) as an attachment.
+// With ?gzip=true the download is gzip-compressed, since/until limit it to a time range.
+func (s *Server) downloadLog(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	stream := r.PathValue("stream")
+
+	if stream != "stdout" && stream != "stderr" && stream != "combined" {
+		http.Error(w, "Stream must be stdout, stderr or combined", http.StatusBadRequest)
+		return
+	}
+
+	svc, err := s.serviceManager.GetService(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	path, err := svc.logPathForStream(stream)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	since, until, err := parseTimeRange(params.Get("since"), params.Get("until"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.log", name, stream)
+	var out io.Writer = w
+	if params.Get("gzip") == "true" {
+		w.Header().Set("Content-Type", "application/gzip")
+		filename += ".gz"
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := writeLogDownload(out, path, stream, since, until); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to download log %s: %v\n", path, err)
+	}
+}
+
+// clearLogs truncates the log files of a service (all streams, or the one in the path) and
+// resets its log buffers. The service keeps running.
+func (s *Server) clearLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	stream := r.PathValue("stream")
+
+	if stream != "" && stream != "stdout" && stream != "stderr" && stream != "combined" {
+		http.Error(w, "Stream must be stdout, stderr or combined", http.StatusBadRequest)
+		return
+	}
+
+	svc, err := s.serviceManager.GetService(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := svc.ClearLogs(stream); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to clear logs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	details := "all streams"
+	if stream != "" {
+		details = stream
+	}
+	s.audit(r, AuditEntry{Action: AuditClearLogs, Service: name, Details: details})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cleared"})
+}
+
+// listAudit returns the audit log (newest first) with filtering and pagination.
+// Query parameters: service, action, actor, since, until (RFC 3339), offset and limit.
+func (s *Server) listAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	since, until, err := parseTimeRange(query.Get("since"), query.Get("until"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := AuditFilter{
+		Service: query.Get("service"),
+		Action:  query.Get("action"),
+		Actor:   query.Get("actor"),
+		Since:   since,
+		Until:   until,
+	}
+	if user := requestUser(r); user.Restricted() {
+		filter.Allowed = func(service string) bool { return s.canAccessService(user, service) }
+	}
+
+	offset, limit := 0, 100
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit (must be 1-1000)", http.StatusBadRequest)
 			return
 		}
+		limit = n
 	}
 
-	// Subscribe to live updates
-	var ch chan string
-	if stream == "stdout" {
-		ch = svc.SubscribeStdout()
-		defer svc.UnsubscribeStdout(ch)
+	entries, more, err := s.serviceManager.AuditLog().Query(filter, offset, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"entries": entries,
+		"more":    more,
+		"offset":  offset,
+		"limit":   limit,
+	})
+}
+
+// downloadLogArchive streams a tar.gz of all services' log files and the configuration
+// (with the authorization credentials redacted). Users limited to some services only get the
+// logs of those services, without the configuration.
+func (s *Server) downloadLogArchive(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	var config []byte
+	if !user.Restricted() {
+		var err error
+		config, err = s.configManager.RedactedYAML()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read configuration: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var services []*Service
+	for _, svc := range s.serviceManager.GetAllServices() {
+		if user.CanAccess(svc.Config) {
+			services = append(services, svc)
+		}
+	}
+
+	filename := fmt.Sprintf("service-manager-logs-%s.tar.gz", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := writeLogArchive(w, services, config); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write log archive: %v\n", err)
+	}
+}
+
+// getRunLog returns the output of a single run from its per-run log file.
+// With ?follow=true the response stays open and streams new output until the run finishes.
+// With ?format=text or ?format=json lines are converted from the on-disk format.
+func (s *Server) getRunLog(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	id := r.PathValue("id")
+	svc, err := s.serviceManager.GetService(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	run, ok := svc.GetRun(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("run %s not found", id), http.StatusNotFound)
+		return
+	}
+	if run.LogFile == "" {
+		http.Error(w, "No log file recorded for this run (run_logs not enabled)", http.StatusNotFound)
+		return
+	}
+
+	file, err := os.Open(run.LogFile)
+	if err != nil {
+		http.Error(w, "Run log file not found (it may have been pruned)", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "text" && format != "json" {
+		http.Error(w, "Format must be text or json", http.StatusBadRequest)
+		return
+	}
+
+	var out io.Writer = w
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		out = &logLineWriter{w: w, format: format, stream: "stdout"}
 	} else {
-		ch = svc.SubscribeStderr()
-		defer svc.UnsubscribeStderr(ch)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if format == "text" {
+			out = &logLineWriter{w: w, format: format, stream: "stdout"}
+		}
+	}
+
+	if r.URL.Query().Get("follow") != "true" {
+		io.Copy(out, file)
+		return
+	}
+
+	// Follow mode: keep copying new output until the run is over
+	flusher, _ := w.(http.Flusher)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if _, err := io.Copy(out, file); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if !svc.IsActiveRun(id) {
+			// Drain anything written between the last copy and the end of the run
+			io.Copy(out, file)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logStreamSender delivers a log stream to a WebSocket or Server-Sent Events client
+type logStreamSender interface {
+	History(lines []LogLine) error
+	Line(line LogLine) error
+	KeepAlive() error
+}
+
+// wsLogSender sends log lines as WebSocket messages (JSON records or plain text)
+type wsLogSender struct {
+	conn       *websocket.Conn
+	jsonFormat bool
+}
+
+func (ws *wsLogSender) History(lines []LogLine) error {
+	if ws.jsonFormat {
+		// One JSON message per line
+		for _, line := range lines {
+			if err := ws.Line(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// All history as a single text message
+	var historyText strings.Builder
+	for _, line := range lines {
+		historyText.WriteString(line.Text)
+		historyText.WriteByte('\n')
+	}
+	if historyText.Len() == 0 {
+		return nil
+	}
+	return ws.conn.WriteMessage(websocket.TextMessage, []byte(historyText.String()))
+}
+
+func (ws *wsLogSender) Line(line LogLine) error {
+	if ws.jsonFormat {
+		return ws.conn.WriteJSON(line)
+	}
+	return ws.conn.WriteMessage(websocket.TextMessage, []byte(line.Text+"\n"))
+}
+
+func (ws *wsLogSender) KeepAlive() error {
+	return nil // The WebSocket library answers pings of the client
+}
+
+// streamLogs streams logs via WebSocket, or as Server-Sent Events for other requests
+// (e.g. curl -N or an EventSource, which resumes with Last-Event-ID)
+func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	stream := r.PathValue("stream")
+
+	if stream != "stdout" && stream != "stderr" && stream != "combined" {
+		http.Error(w, "Stream must be stdout, stderr or combined", http.StatusBadRequest)
+		return
+	}
+
+	svc, err := s.serviceManager.GetService(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Optional output format, time range and number of history lines
+	query := r.URL.Query()
+	jsonFormat := query.Get("format") == "json"
+	since, until, err := parseTimeRange(query.Get("since"), query.Get("until"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	minLevel, err := parseLogLevelParam(query.Get("level"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tail := -1
+	if v := query.Get("tail"); v != "" {
+		tail, err = strconv.Atoi(v)
+		if err != nil || tail < 0 || tail > maxRangeLines {
+			http.Error(w, fmt.Sprintf("Invalid tail (must be 0-%d)", maxRangeLines), http.StatusBadRequest)
+			return
+		}
+	}
+	useSSE := !websocket.IsWebSocketUpgrade(r)
+	after := int64(-1)
+	afterParam := query.Get("after")
+	if id := r.Header.Get("Last-Event-ID"); useSSE && id != "" {
+		afterParam = id // Reconnecting EventSource
+	}
+	if afterParam != "" {
+		after, err = strconv.ParseInt(afterParam, 10, 64)
+		if err != nil || after < 0 {
+			http.Error(w, "Invalid after (must be a sequence number)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var sender logStreamSender
+	if useSSE {
+		sse, err := newSSEWriter(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sender = &sseLogSender{sse: sse, jsonFormat: jsonFormat}
+	} else {
+		// Upgrade to WebSocket
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sender = &wsLogSender{conn: conn, jsonFormat: jsonFormat}
+	}
+
+	// Subscribe before reading the history, so no line is missed in between
+	ch, unsubscribe := subscribeLogStream(svc, stream)
+	defer unsubscribe()
+	history := logStreamHistory(svc, stream, after, tail)
+
+	// Live lines up to the last history line were already sent
+	lastSeq := after
+	var filtered []LogLine
+	for _, line := range history {
+		lastSeq = max(lastSeq, line.Seq)
+		if line.streamable(since, until, minLevel) {
+			filtered = append(filtered, line)
+		}
+	}
+	if err := sender.History(filtered); err != nil {
+		return
+	}
+
+	// A closed time range has no live updates
+	if !until.IsZero() {
+		return
 	}
 
 	// Stream live logs
-	for msg := range ch {
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
 			return
+		case <-keepAlive.C:
+			if err := sender.KeepAlive(); err != nil {
+				return
+			}
+		case line, ok := <-ch:
+			if !ok {
+				return
+			}
+			if line.Dropped == 0 && line.Seq <= lastSeq || !line.streamable(since, until, minLevel) {
+				continue
+			}
+			if err := sender.Line(line); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// subscribeLogStream subscribes to the live lines of a stream. Returns the channel and a
+// function that unsubscribes.
+func subscribeLogStream(svc *Service, stream string) (chan LogLine, func()) {
+	var ch chan LogLine
+	switch stream {
+	case "stdout":
+		ch = svc.SubscribeStdout()
+		return ch, func() { svc.UnsubscribeStdout(ch) }
+	case "stderr":
+		ch = svc.SubscribeStderr()
+		return ch, func() { svc.UnsubscribeStderr(ch) }
+	}
+	ch = svc.SubscribeCombined()
+	return ch, func() { svc.UnsubscribeCombined(ch) }
+}
+
+// logStreamHistory returns the lines a log stream starts with: the lines after sequence number
+// after (>= 0) preceded by a dropped-lines marker if some are gone, the last tail lines
+// (tail >= 0), or the buffer
+func logStreamHistory(svc *Service, stream string, after int64, tail int) []LogLine {
+	var history []LogLine
+	switch {
+	case after >= 0:
+		var missed int64
+		history, missed = svc.GetLinesAfter(stream, after)
+		if missed > 0 {
+			history = append([]LogLine{droppedMarker(LogLine{Time: time.Now(), Stream: stream}, int(missed))}, history...)
+		}
+		return history
+	case stream == "stdout":
+		history = svc.GetStdoutBuffer()
+	case stream == "stderr":
+		history = svc.GetStderrBuffer()
+	default:
+		history = svc.GetCombinedBuffer()
+	}
+	if tail >= 0 {
+		history = svc.TailLogLines(stream, history, tail)
+	}
+	return history
+}
+
+// streamable reports whether a line passes the time range and level filters of a log stream.
+// Dropped-lines markers are always sent.
+func (l LogLine) streamable(since, until time.Time, minLevel int) bool {
+	return l.Dropped > 0 || l.inTimeRange(since, until) && l.hasMinLevel(minLevel)
+}
+
+// parseLogLevelParam parses an optional minimum level query parameter into a level rank
+func parseLogLevelParam(param string) (int, error) {
+	if param == "" {
+		return 0, nil
+	}
+	level := normalizeLogLevel(param)
+	if level == "" {
+		return 0, fmt.Errorf("invalid level %q (must be one of %s)", param, strings.Join(logLevels, ", "))
+	}
+	return logLevelRank(level), nil
+}
+
+// parseTimeRange parses optional RFC 3339 since/until query parameters
+func parseTimeRange(sinceParam, untilParam string) (since, until time.Time, err error) {
+	if sinceParam != "" {
+		if since, err = time.Parse(time.RFC3339, sinceParam); err != nil {
+			return since, until, fmt.Errorf("invalid since time %q (expected RFC 3339)", sinceParam)
+		}
+	}
+	if untilParam != "" {
+		if until, err = time.Parse(time.RFC3339, untilParam); err != nil {
+			return since, until, fmt.Errorf("invalid until time %q (expected RFC 3339)", untilParam)
 		}
 	}
+	return since, until, nil
 }
 
 // handleStatic serves static files from embedded filesystem
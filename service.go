@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -19,14 +19,19 @@ const (
 	logBufferSize      = 10 * 1024       // 10KB circular buffer
 	restartDelay       = 5 * time.Second // Delay between restart attempts
 	maxRestartAttempts = 5               // Maximum consecutive restart attempts before giving up
+	logDrainTimeout    = 2 * time.Second // Maximum time to wait for remaining output after the process exits
 )
 
 // FailureCallback is called when a service fails
 type FailureCallback func(serviceName string, consecutiveFailures int, exitCode int, err error)
 
+// ExitCallback is called with the finished run record every time the service process exits
+type ExitCallback func(serviceName string, run RunRecord)
+
 // Service represents a managed service instance
 type Service struct {
 	Config    ServiceConfig
+	global    GlobalConfig // Global settings (log rotation defaults)
 	cmd       *exec.Cmd
 	winJob    interface{} // Placeholder for Windows Job Object (only used on Windows)
 	running   bool
@@ -39,97 +44,186 @@ type Service struct {
 	lastExitCode int
 	lastDuration time.Duration
 
+	// Run history
+	runHistory *RunHistory
+	currentRun *RunRecord
+
 	// Failure tracking
 	consecutiveFailures int
 	lastError           error
 	failureCallback     FailureCallback
+	exitCallback        ExitCallback
+	succeeded           bool // Last run in this session exited with code 0 (used for "after" dependencies)
+
+	stdoutBuf   *CircularBuffer
+	stderrBuf   *CircularBuffer
+	combinedBuf *CircularBuffer // Both streams interleaved in arrival order
+
+	stdoutFile   *LogFile
+	stderrFile   *LogFile
+	combinedFile *LogFile // Additional combined log file (log: both, nil otherwise)
+	runLogFile   *os.File // Per-run log file of the current run (nil if disabled)
 
-	stdoutBuf *CircularBuffer
-	stderrBuf *CircularBuffer
+	stdoutBroadcast   *Broadcaster
+	stderrBroadcast   *Broadcaster
+	combinedBroadcast *Broadcaster
 
-	stdoutFile *os.File
-	stderrFile *os.File
+	logMu sync.Mutex // Serializes log writes so every output sees lines in arrival order
+	sinks []LogSink  // External log destinations (protected by logMu)
 
-	stdoutBroadcast *Broadcaster
-	stderrBroadcast *Broadcaster
+	alerts *alertMatcher // Output patterns that fire the webhook (nil = no alerts)
+	events *EventBus     // Receives start/exit/restart events (nil = not published)
+	audit  *AuditLog     // Records automatic restarts and give-ups (nil = not recorded)
+
+	fieldParser *logFieldParser // Extracts level/message/time from structured output
+	errorLines  errorRate       // Error (or worse) lines per minute
 
 	mu       sync.RWMutex
 	stopChan chan struct{}
 	stopOnce sync.Once // Ensures stopChan is only closed once
 }
 
-// CircularBuffer is a fixed-size ring buffer for log lines
+// CircularBuffer is a ring buffer of log lines bounded by the total size of their text.
+// Every written line gets the next sequence number of the buffer.
 type CircularBuffer struct {
-	data []byte
-	size int
-	mu   sync.RWMutex
+	lines []LogLine // Buffered lines are lines[head:]
+	head  int       // Index of the oldest buffered line; dropped lines before it are compacted away occasionally
+	bytes int       // Total text size of the buffered lines (including newlines)
+	size  int
+	seq   int64 // Sequence number of the last written line
+	start int64 // Lowest sequence number the buffer can tell gaps for (raised by Clear)
+	mu    sync.RWMutex
 }
 
-// NewCircularBuffer creates a new circular buffer
+// NewCircularBuffer creates a new circular buffer holding up to size bytes of text.
+// Sequence numbers start at the current time in microseconds, so they keep increasing
+// when the buffer is recreated (config change or manager restart).
 func NewCircularBuffer(size int) *CircularBuffer {
+	start := time.Now().UnixMicro()
 	return &CircularBuffer{
-		data: make([]byte, 0, size),
-		size: size,
+		size:  size,
+		seq:   start - 1,
+		start: start,
 	}
 }
 
-// Write implements io.Writer
-func (cb *CircularBuffer) Write(p []byte) (n int, err error) {
+// Write appends a line, dropping the oldest lines once the buffer is full.
+// Returns the sequence number assigned to the line.
+func (cb *CircularBuffer) Write(line LogLine) int64 {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	// If adding this would exceed capacity, remove from the front
-	if len(cb.data)+len(p) > cb.size {
-		excess := len(cb.data) + len(p) - cb.size
-		if excess >= len(cb.data) {
-			// New data is larger than buffer, just keep the tail
-			cb.data = append([]byte{}, p[len(p)-cb.size:]...)
-		} else {
-			cb.data = append(cb.data[excess:], p...)
+	cb.seq++
+	line.Seq = cb.seq
+
+	// A single line may not exceed the buffer; cut it without splitting a character or escape sequence
+	if len(line.Text) >= cb.size {
+		line.Text = truncateLogText(line.Text, cb.size-1)
+		line.HTML = ""
+	}
+
+	cb.lines = append(cb.lines, line)
+	cb.bytes += len(line.Text) + 1
+
+	// Remove from the front, but always keep the newest line
+	for cb.bytes > cb.size && cb.head < len(cb.lines)-1 {
+		cb.bytes -= len(cb.lines[cb.head].Text) + 1
+		cb.lines[cb.head] = LogLine{} // Release the text
+		cb.head++
+	}
+
+	// Move the lines to the front once half of the slice is dropped lines, so writes stay O(1) amortized
+	if cb.head > 0 && cb.head >= len(cb.lines)/2 {
+		n := copy(cb.lines, cb.lines[cb.head:])
+		clear(cb.lines[n:])
+		cb.lines = cb.lines[:n]
+		cb.head = 0
+	}
+	return cb.seq
+}
+
+// Clear removes the lines of a stream from the buffer ("" = all lines)
+func (cb *CircularBuffer) Clear(stream string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if stream == "" {
+		cb.start = cb.seq + 1 // Cleared lines are not reported as missed
+	}
+
+	var kept []LogLine
+	cb.bytes = 0
+	for _, line := range cb.lines[cb.head:] {
+		if stream != "" && line.Stream != stream {
+			kept = append(kept, line)
+			cb.bytes += len(line.Text) + 1
+		}
+	}
+	cb.lines = kept
+	cb.head = 0
+}
+
+// LinesAfter returns the buffered lines with a sequence number above seq, and the number of
+// lines after seq that are no longer buffered. A seq from before the buffer existed (e.g. a
+// previous manager run) returns all lines.
+func (cb *CircularBuffer) LinesAfter(seq int64) ([]LogLine, int64) {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	var result []LogLine
+	for _, line := range cb.lines[cb.head:] {
+		if line.Seq > seq {
+			result = append(result, line)
 		}
-	} else {
-		cb.data = append(cb.data, p...)
 	}
 
-	return len(p), nil
+	var missed int64
+	if seq >= cb.start-1 {
+		next := cb.seq + 1 // Nothing buffered after seq
+		if len(result) > 0 {
+			next = result[0].Seq
+		}
+		missed = max(next-seq-1, 0)
+	}
+	return result, missed
 }
 
-// Read returns the current buffer contents
-func (cb *CircularBuffer) Read() []byte {
+// Lines returns a copy of the buffered lines, oldest first
+func (cb *CircularBuffer) Lines() []LogLine {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
 
-	result := make([]byte, len(cb.data))
-	copy(result, cb.data)
+	result := make([]LogLine, len(cb.lines)-cb.head)
+	copy(result, cb.lines[cb.head:])
 	return result
 }
 
-// Broadcaster broadcasts messages to multiple channels
+// Broadcaster broadcasts log lines to multiple channels
 type Broadcaster struct {
-	clients map[chan string]bool
-	closed  map[chan string]bool // Track closed channels
-	mu      sync.RWMutex
+	clients map[chan LogLine]int  // Lines dropped for the client since its last delivered line
+	closed  map[chan LogLine]bool // Track closed channels
+	mu      sync.Mutex
 }
 
 // NewBroadcaster creates a new broadcaster
 func NewBroadcaster() *Broadcaster {
 	return &Broadcaster{
-		clients: make(map[chan string]bool),
-		closed:  make(map[chan string]bool),
+		clients: make(map[chan LogLine]int),
+		closed:  make(map[chan LogLine]bool),
 	}
 }
 
 // Subscribe adds a new client channel
-func (b *Broadcaster) Subscribe() chan string {
-	ch := make(chan string, 100)
+func (b *Broadcaster) Subscribe() chan LogLine {
+	ch := make(chan LogLine, 100)
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.clients[ch] = true
+	b.clients[ch] = 0
 	return ch
 }
 
 // Unsubscribe removes a client channel
-func (b *Broadcaster) Unsubscribe(ch chan string) {
+func (b *Broadcaster) Unsubscribe(ch chan LogLine) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	if b.closed[ch] {
@@ -140,29 +234,66 @@ func (b *Broadcaster) Unsubscribe(ch chan string) {
 	b.closed[ch] = true
 }
 
-// Broadcast sends a message to all subscribers
-func (b *Broadcaster) Broadcast(msg string) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+// Broadcast sends a line to all subscribers. Lines are dropped for subscribers that fall
+// behind (full channel); once they catch up they first receive a dropped-lines marker.
+func (b *Broadcaster) Broadcast(line LogLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	for ch := range b.clients {
-		select {
-		case ch <- msg:
-		default:
-			// Skip if channel is full
+	for ch, dropped := range b.clients {
+		if dropped > 0 {
+			select {
+			case ch <- droppedMarker(line, dropped):
+				dropped = 0
+			default:
+			}
+		}
+
+		if dropped == 0 {
+			select {
+			case ch <- line:
+			default:
+				dropped++
+			}
+		} else {
+			dropped++
 		}
+		b.clients[ch] = dropped
+	}
+}
+
+// droppedMarker returns the line that tells a subscriber how many lines it missed before line
+func droppedMarker(line LogLine, dropped int) LogLine {
+	return LogLine{
+		Time:    line.Time,
+		Stream:  line.Stream,
+		Text:    fmt.Sprintf("[service-manager] %d lines dropped", dropped),
+		Dropped: dropped,
 	}
 }
 
 // New creates a new service instance
-func NewService(cfg ServiceConfig) *Service {
+func NewService(cfg ServiceConfig, global GlobalConfig) *Service {
 	svc := &Service{
-		Config:          cfg,
-		stdoutBuf:       NewCircularBuffer(logBufferSize),
-		stderrBuf:       NewCircularBuffer(logBufferSize),
-		stdoutBroadcast: NewBroadcaster(),
-		stderrBroadcast: NewBroadcaster(),
-		stopChan:        make(chan struct{}),
+		Config:            cfg,
+		global:            global,
+		stdoutBuf:         NewCircularBuffer(logBufferSize),
+		stderrBuf:         NewCircularBuffer(logBufferSize),
+		combinedBuf:       NewCircularBuffer(2 * logBufferSize),
+		stdoutBroadcast:   NewBroadcaster(),
+		stderrBroadcast:   NewBroadcaster(),
+		combinedBroadcast: NewBroadcaster(),
+		fieldParser:       newLogFieldParser(cfg.LogFormat, cfg.LogFields),
+		alerts:            newAlertMatcher(cfg.Name, cfg.Alerts),
+		stopChan:          make(chan struct{}),
+		runHistory:        NewRunHistory(filepath.Join(global.EffectiveLogDir(), fmt.Sprintf("%s-runs.jsonl", cfg.Name))),
+	}
+
+	// Restore last run statistics from the run history
+	if last, ok := svc.runHistory.Last(); ok {
+		svc.lastRunTime = last.StartTime
+		svc.lastExitCode = last.ExitCode
+		svc.lastDuration = time.Duration(last.Duration * float64(time.Second))
 	}
 
 	// Load existing log files into buffers
@@ -178,8 +309,48 @@ func (s *Service) SetFailureCallback(callback FailureCallback) {
 	s.failureCallback = callback
 }
 
-// Start starts the service
+// SetExitCallback sets the callback to be called every time the service process exits
+func (s *Service) SetExitCallback(callback ExitCallback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exitCallback = callback
+}
+
+// SetAlertCallback sets the callback to be called when an output line matches one of the service's alerts
+func (s *Service) SetAlertCallback(callback AlertCallback) {
+	s.alerts.SetCallback(func(alert LogAlert) {
+		callback(s.Config.Name, alert)
+	})
+}
+
+// SetLogSinks sets the external destinations that receive the service's output
+func (s *Service) SetLogSinks(sinks []LogSink) {
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+	s.sinks = sinks
+}
+
+// SetEventBus sets the bus that receives the service's state changes
+func (s *Service) SetEventBus(bus *EventBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = bus
+}
+
+// SetAuditLog sets the log that records the automatic actions of the service
+func (s *Service) SetAuditLog(audit *AuditLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = audit
+}
+
+// Start starts the service (manual trigger)
 func (s *Service) Start() error {
+	return s.StartRun(RunOptions{Trigger: TriggerManual})
+}
+
+// StartRun starts the service, recording the run with the given options
+func (s *Service) StartRun(opts RunOptions) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -201,9 +372,9 @@ func (s *Service) Start() error {
 		return fmt.Errorf("empty command")
 	}
 
-	// Create command (first part is the command, rest are arguments)
+	// Create command (first part is the command, rest are arguments plus any extra run arguments)
 	cmdName := parts[0]
-	cmdArgs := parts[1:]
+	cmdArgs := append(parts[1:], opts.Args...)
 	s.cmd = exec.Command(cmdName, cmdArgs...)
 
 	// Configure Windows-specific process attributes (hide console windows)
@@ -246,46 +417,99 @@ func (s *Service) Start() error {
 		envMap[k] = v
 	}
 
+	// Apply per-run overrides (these override everything)
+	for k, v := range opts.Env {
+		envMap[k] = v
+	}
+
 	// Convert map back to slice for cmd.Env
 	s.cmd.Env = make([]string, 0, len(envMap))
 	for k, v := range envMap {
 		s.cmd.Env = append(s.cmd.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	// Create pipes for stdout/stderr
-	stdout, err := s.cmd.StdoutPipe()
+	// Create pipes for stdout/stderr. We own the read ends (instead of using cmd.StdoutPipe)
+	// so that cmd.Wait does not close them before the readers have drained all output.
+	stdout, stdoutW, err := os.Pipe()
 	if err != nil {
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	stderr, err := s.cmd.StderrPipe()
+	stderr, stderrW, err := os.Pipe()
 	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
 		return fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
+	s.cmd.Stdout = stdoutW
+	s.cmd.Stderr = stderrW
+
+	// Remember where this run begins in the log files
+	stdoutStart := s.stdoutFile.Offset()
+	stderrStart := s.stderrFile.Offset()
+
 	// Start the process (Windows: start + assign to Job Object before execution)
-	if err := platformStartProcess(s); err != nil {
+	err = platformStartProcess(s)
+
+	// The child has its own copies of the write ends now
+	stdoutW.Close()
+	stderrW.Close()
+
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
 		s.closeLogFiles()
 		return fmt.Errorf("failed to start service %s: %w", s.Config.Name, err)
 	}
 
 	s.running = true
+	s.succeeded = false
 	s.pid = s.cmd.Process.Pid
 	s.startTime = time.Now()
+	s.currentRun = &RunRecord{
+		ID:          newRunID(s.startTime),
+		Trigger:     opts.Trigger,
+		Args:        opts.Args,
+		EnvKeys:     envKeys(opts.Env),
+		PID:         s.pid,
+		StartTime:   s.startTime,
+		StdoutStart: stdoutStart,
+		StderrStart: stderrStart,
+	}
 
-	// Log service start
-	if s.Config.IsScheduled() {
-		s.logServiceEvent(fmt.Sprintf("Starting scheduled service '%s' (PID: %d)", s.Config.Name, s.pid))
-	} else {
-		s.logServiceEvent(fmt.Sprintf("Starting continuous service '%s' (PID: %d)", s.Config.Name, s.pid))
+	// Open the per-run log file if configured
+	if s.Config.RunLogs != nil {
+		if err := s.openRunLogFile(s.currentRun); err != nil {
+			s.logServiceEvent(fmt.Sprintf("Failed to open run log file: %v", err))
+		}
 	}
 
+	// Log service start
+	s.logServiceEvent(fmt.Sprintf("Starting %s service '%s' (PID: %d)", s.Config.Kind(), s.Config.Name, s.pid))
+	s.events.Publish(Event{Type: EventStarted, Service: s.Config.Name, PID: s.pid, Trigger: opts.Trigger})
+
 	// Start log readers
-	go s.readLogs(stdout, s.stdoutFile, s.stdoutBuf, s.stdoutBroadcast)
-	go s.readLogs(stderr, s.stderrFile, s.stderrBuf, s.stderrBroadcast)
+	runID, pid := s.currentRun.ID, s.pid
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		s.readLogs(stdout, LogLine{Stream: "stdout", RunID: runID, PID: pid}, s.stdoutFile, s.runLogFile, s.stdoutBuf, s.stdoutBroadcast)
+	}()
+	go func() {
+		defer readers.Done()
+		s.readLogs(stderr, LogLine{Stream: "stderr", RunID: runID, PID: pid}, s.stderrFile, s.runLogFile, s.stderrBuf, s.stderrBroadcast)
+	}()
+
+	readersDone := make(chan struct{})
+	go func() {
+		readers.Wait()
+		close(readersDone)
+	}()
 
 	// Monitor process
-	go s.monitor()
+	go s.monitor(readersDone, stdout, stderr)
 
 	return nil
 }
@@ -388,7 +612,27 @@ func (s *Service) Restart() error {
 	s.stopOnce = sync.Once{}
 	s.mu.Unlock()
 
-	return s.Start()
+	if err := s.Start(); err != nil {
+		return err
+	}
+	s.publish(Event{Type: EventRestarted})
+	return nil
+}
+
+// publish sends an event about the service to the event bus
+func (s *Service) publish(event Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	event.Service = s.Config.Name
+	s.events.Publish(event)
+}
+
+// HasSucceeded returns whether the service has finished a run successfully since the manager started
+// and is not currently running
+func (s *Service) HasSucceeded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.succeeded && !s.running
 }
 
 // IsRunning returns whether the service is running
@@ -440,33 +684,90 @@ type Status struct {
 	ConsecutiveFailures int           `json:"consecutiveFailures"`
 }
 
-// GetStdoutBuffer returns the stdout buffer contents
-func (s *Service) GetStdoutBuffer() []byte {
-	return s.stdoutBuf.Read()
+// GetRuns returns up to limit run records starting at offset (newest first) and the total count
+func (s *Service) GetRuns(offset, limit int) ([]RunRecord, int) {
+	return s.runHistory.List(offset, limit)
 }
 
-// GetStderrBuffer returns the stderr buffer contents
-func (s *Service) GetStderrBuffer() []byte {
-	return s.stderrBuf.Read()
+// LastRun returns the most recent finished run
+func (s *Service) LastRun() (RunRecord, bool) {
+	return s.runHistory.Last()
+}
+
+// GetRun returns the run record with the given ID, including the currently active run
+func (s *Service) GetRun(id string) (RunRecord, bool) {
+	s.mu.RLock()
+	if s.currentRun != nil && s.currentRun.ID == id {
+		run := *s.currentRun
+		s.mu.RUnlock()
+		return run, true
+	}
+	s.mu.RUnlock()
+
+	return s.runHistory.Get(id)
+}
+
+// IsActiveRun returns whether the run with the given ID is still executing
+func (s *Service) IsActiveRun(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.currentRun != nil && s.currentRun.ID == id
+}
+
+// GetStdoutBuffer returns the buffered stdout lines
+func (s *Service) GetStdoutBuffer() []LogLine {
+	return s.stdoutBuf.Lines()
+}
+
+// GetStderrBuffer returns the buffered stderr lines
+func (s *Service) GetStderrBuffer() []LogLine {
+	return s.stderrBuf.Lines()
+}
+
+// GetCombinedBuffer returns the buffered lines of both streams in arrival order
+func (s *Service) GetCombinedBuffer() []LogLine {
+	return s.combinedBuf.Lines()
+}
+
+// GetLinesAfter returns the buffered lines of a stream after sequence number seq, and the
+// number of lines after seq that are no longer buffered
+func (s *Service) GetLinesAfter(stream string, seq int64) ([]LogLine, int64) {
+	switch stream {
+	case "stdout":
+		return s.stdoutBuf.LinesAfter(seq)
+	case "stderr":
+		return s.stderrBuf.LinesAfter(seq)
+	}
+	return s.combinedBuf.LinesAfter(seq)
+}
+
+// SubscribeCombined subscribes to updates of both streams
+func (s *Service) SubscribeCombined() chan LogLine {
+	return s.combinedBroadcast.Subscribe()
+}
+
+// UnsubscribeCombined unsubscribes from combined updates
+func (s *Service) UnsubscribeCombined(ch chan LogLine) {
+	s.combinedBroadcast.Unsubscribe(ch)
 }
 
 // SubscribeStdout subscribes to stdout updates
-func (s *Service) SubscribeStdout() chan string {
+func (s *Service) SubscribeStdout() chan LogLine {
 	return s.stdoutBroadcast.Subscribe()
 }
 
 // SubscribeStderr subscribes to stderr updates
-func (s *Service) SubscribeStderr() chan string {
+func (s *Service) SubscribeStderr() chan LogLine {
 	return s.stderrBroadcast.Subscribe()
 }
 
 // UnsubscribeStdout unsubscribes from stdout updates
-func (s *Service) UnsubscribeStdout(ch chan string) {
+func (s *Service) UnsubscribeStdout(ch chan LogLine) {
 	s.stdoutBroadcast.Unsubscribe(ch)
 }
 
 // UnsubscribeStderr unsubscribes from stderr updates
-func (s *Service) UnsubscribeStderr(ch chan string) {
+func (s *Service) UnsubscribeStderr(ch chan LogLine) {
 	s.stderrBroadcast.Unsubscribe(ch)
 }
 
@@ -474,56 +775,178 @@ func (s *Service) UnsubscribeStderr(ch chan string) {
 func (s *Service) WriteStderrLog(msg string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
 
+	line := LogLine{Time: time.Now(), Stream: "stderr", Text: strings.TrimSuffix(msg, "\n"), Offset: s.stderrFile.Offset()}
 	if s.stderrFile != nil {
-		s.stderrFile.WriteString(msg)
+		s.stderrFile.WriteString(formatLogLine(line, s.logFileFormat()))
 	}
-	s.stderrBuf.Write([]byte(msg))
-	s.stderrBroadcast.Broadcast(msg)
+	line.Seq = s.stderrBuf.Write(line)
+	s.stderrBroadcast.Broadcast(line)
+	s.writeCombined(line, line, s.logFileFormat())
 }
 
 // logServiceEvent writes a service manager event to both stdout and stderr logs
 // Format: [service-manager][YYYY-MM-DD HH:MM:SS] message
 func (s *Service) logServiceEvent(message string) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logMsg := fmt.Sprintf("[service-manager][%s] %s\n", timestamp, message)
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+
+	now := time.Now()
+	text := fmt.Sprintf("[service-manager][%s] %s", now.Format("2006-01-02 15:04:05"), message)
+	format := s.logFileFormat()
+
+	var runID string
+	if s.currentRun != nil {
+		runID = s.currentRun.ID
+	}
 
 	// Write to stdout
+	stdoutLine := LogLine{Time: now, Stream: "stdout", RunID: runID, PID: s.pid, Text: text, Offset: s.stdoutFile.Offset()}
 	if s.stdoutFile != nil {
-		s.stdoutFile.WriteString(logMsg)
+		s.stdoutFile.WriteString(formatLogLine(stdoutLine, format))
+	}
+	stdoutLine.Seq = s.stdoutBuf.Write(stdoutLine)
+	s.stdoutBroadcast.Broadcast(stdoutLine)
+
+	// Write to stderr (combined logs already have it)
+	stderrLine := stdoutLine
+	stderrLine.Stream = "stderr"
+	if s.stderrFile != s.stdoutFile {
+		stderrLine.Offset = s.stderrFile.Offset()
+	}
+	if s.stderrFile != nil && s.stderrFile != s.stdoutFile {
+		s.stderrFile.WriteString(formatLogLine(stderrLine, format))
 	}
-	s.stdoutBuf.Write([]byte(logMsg))
-	s.stdoutBroadcast.Broadcast(logMsg)
+	stderrLine.Seq = s.stderrBuf.Write(stderrLine)
+	s.stderrBroadcast.Broadcast(stderrLine)
 
-	// Write to stderr
-	if s.stderrFile != nil {
-		s.stderrFile.WriteString(logMsg)
+	// Write once to the per-run log and the combined stream
+	if s.runLogFile != nil {
+		s.runLogFile.WriteString(formatLogLine(stdoutLine, format))
 	}
-	s.stderrBuf.Write([]byte(logMsg))
-	s.stderrBroadcast.Broadcast(logMsg)
+	s.writeCombined(stdoutLine, stdoutLine, format)
 }
 
-// openLogFiles opens the log files for writing
-func (s *Service) openLogFiles() error {
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		return fmt.Errorf("failed to create logs directory: %w", err)
+// writeCombined appends a line to the combined buffer, broadcast and (optional) combined file.
+// logged is the line as written to files and sinks (without escape sequences with ansi: strip
+// or render). The caller must hold logMu.
+func (s *Service) writeCombined(line, logged LogLine, format string) {
+	if s.combinedFile != nil {
+		line.Offset = s.combinedFile.Offset()
+		logged.Offset = line.Offset
+		s.combinedFile.WriteString(formatLogLine(logged, format))
+	}
+	line.Seq = s.combinedBuf.Write(line)
+	s.combinedBroadcast.Broadcast(line)
+	for _, sink := range s.sinks {
+		sink.Send(s.Config.Name, logged)
 	}
+}
 
-	stdoutPath := filepath.Join("logs", fmt.Sprintf("%s-stdout.log", s.Config.Name))
-	stderrPath := filepath.Join("logs", fmt.Sprintf("%s-stderr.log", s.Config.Name))
+// openLogFiles opens the log files for writing
+func (s *Service) openLogFiles() error {
+	rotation := s.Config.EffectiveLogRotation(s.global)
 
 	var err error
-	s.stdoutFile, err = os.OpenFile(stdoutPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	s.stdoutFile, err = OpenLogFile(s.stdoutLogTemplate(), rotation)
 	if err != nil {
 		return fmt.Errorf("failed to open stdout log file: %w", err)
 	}
 
-	s.stderrFile, err = os.OpenFile(stderrPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	// Combined logs share a single file
+	if s.Config.IsCombinedLog() {
+		s.stderrFile = s.stdoutFile
+		return nil
+	}
+
+	s.stderrFile, err = OpenLogFile(s.stderrLogTemplate(), rotation)
 	if err != nil {
 		s.stdoutFile.Close()
 		return fmt.Errorf("failed to open stderr log file: %w", err)
 	}
 
+	if s.Config.HasCombinedLogFile() {
+		combinedFile, err := OpenLogFile(s.combinedLogTemplate(), rotation)
+		if err != nil {
+			s.stdoutFile.Close()
+			s.stderrFile.Close()
+			return fmt.Errorf("failed to open combined log file: %w", err)
+		}
+		s.logMu.Lock()
+		s.combinedFile = combinedFile
+		s.logMu.Unlock()
+	}
+
+	return nil
+}
+
+// logDir returns the directory holding the logs of this service
+func (s *Service) logDir() string {
+	return s.global.EffectiveLogDir()
+}
+
+// logPathTemplate resolves a configured log path (relative to the log directory) and expands {name}.
+// {date} is left for the log file to expand, so dated files change while the service runs.
+func (s *Service) logPathTemplate(configured, defaultName string) string {
+	return s.Config.logPathTemplate(s.global, configured, defaultName)
+}
+
+// stdoutLogTemplate returns the path template of the stdout (or combined) log file
+func (s *Service) stdoutLogTemplate() string {
+	if s.Config.IsCombinedLog() {
+		return s.logPathTemplate(s.Config.StdoutPath, "{name}.log")
+	}
+	return s.logPathTemplate(s.Config.StdoutPath, "{name}-stdout.log")
+}
+
+// stderrLogTemplate returns the path template of the stderr log file (the combined file for combined logs)
+func (s *Service) stderrLogTemplate() string {
+	if s.Config.IsCombinedLog() {
+		return s.stdoutLogTemplate()
+	}
+	return s.logPathTemplate(s.Config.StderrPath, "{name}-stderr.log")
+}
+
+// combinedLogTemplate returns the path template of the combined log file (log: combined or both)
+func (s *Service) combinedLogTemplate() string {
+	if s.Config.IsCombinedLog() {
+		return s.stdoutLogTemplate()
+	}
+	return s.logPathTemplate(s.Config.CombinedPath, "{name}.log")
+}
+
+// stdoutLogPath returns the current path of the stdout (or combined) log file
+func (s *Service) stdoutLogPath() string {
+	return expandLogDate(s.stdoutLogTemplate(), time.Now())
+}
+
+// stderrLogPath returns the current path of the stderr log file
+func (s *Service) stderrLogPath() string {
+	return expandLogDate(s.stderrLogTemplate(), time.Now())
+}
+
+// runLogDir returns the directory holding the per-run log files of this service
+func (s *Service) runLogDir() string {
+	return s.Config.runLogDir(s.global)
+}
+
+// openRunLogFile creates the log file for a single run and records its path
+func (s *Service) openRunLogFile(run *RunRecord) error {
+	dir := s.runLogDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create run log directory: %w", err)
+	}
+
+	path := filepath.Join(dir, run.ID+".log")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open run log file: %w", err)
+	}
+
+	s.runLogFile = file
+	run.LogFile = path
 	return nil
 }
 
@@ -537,89 +960,157 @@ func (s *Service) closeLogFiles() {
 		s.stderrFile.Close()
 		s.stderrFile = nil
 	}
+	if s.runLogFile != nil {
+		s.runLogFile.Close()
+		s.runLogFile = nil
+	}
+
+	s.logMu.Lock()
+	if s.combinedFile != nil {
+		s.combinedFile.Close()
+		s.combinedFile = nil
+	}
+	s.logMu.Unlock()
 }
 
 // loadExistingLogs loads the last portion of existing log files into buffers
 func (s *Service) loadExistingLogs() {
-	stdoutPath := filepath.Join("logs", fmt.Sprintf("%s-stdout.log", s.Config.Name))
-	stderrPath := filepath.Join("logs", fmt.Sprintf("%s-stderr.log", s.Config.Name))
-
-	s.loadLogFile(stdoutPath, s.stdoutBuf)
-	s.loadLogFile(stderrPath, s.stderrBuf)
-}
-
-// loadLogFile loads the last portion of a log file into a buffer
-func (s *Service) loadLogFile(path string, buf *CircularBuffer) {
-	file, err := os.Open(path)
-	if err != nil {
-		// File doesn't exist or can't be opened, that's okay
-		return
+	// A combined file holds both streams in order, so it fills all buffers
+	if s.Config.IsCombinedLog() || s.Config.HasCombinedLogFile() {
+		path := expandLogDate(s.combinedLogTemplate(), time.Now())
+		lines := s.annotateLines(readLogLines(path, "stdout", 2*logBufferSize))
+		for _, line := range lines {
+			s.combinedBuf.Write(line)
+		}
+		if s.Config.IsCombinedLog() {
+			for _, line := range lines {
+				if line.Stream == "stderr" {
+					s.stderrBuf.Write(line)
+				} else {
+					s.stdoutBuf.Write(line)
+				}
+			}
+			return
+		}
 	}
-	defer file.Close()
 
-	// Get file size
-	stat, err := file.Stat()
-	if err != nil {
-		return
+	stdoutLines := s.annotateLines(readLogLines(s.stdoutLogPath(), "stdout", logBufferSize))
+	stderrLines := s.annotateLines(readLogLines(s.stderrLogPath(), "stderr", logBufferSize))
+	for _, line := range stdoutLines {
+		s.stdoutBuf.Write(line)
 	}
-
-	fileSize := stat.Size()
-	if fileSize == 0 {
-		return
+	for _, line := range stderrLines {
+		s.stderrBuf.Write(line)
 	}
 
-	// Read the last logBufferSize bytes (or entire file if smaller)
-	readSize := int64(logBufferSize)
-	offset := int64(0)
-	if fileSize > readSize {
-		offset = fileSize - readSize
-	} else {
-		readSize = fileSize
+	// Without a combined file, interleave the separate streams by time
+	if !s.Config.HasCombinedLogFile() {
+		for _, line := range mergeLogLines(stdoutLines, stderrLines) {
+			s.combinedBuf.Write(line)
+		}
 	}
+}
 
-	// Seek to the offset
-	if _, err := file.Seek(offset, 0); err != nil {
-		return
-	}
+// readLogLines reads the last whole lines of a log (including rotated segments), up to size bytes
+func readLogLines(path, stream string, size int64) []LogLine {
+	_, end := logBounds(path)
+	data, start := readLogLineRange(path, end-size, size)
+	return parseLogLinesAt(data, stream, start)
+}
 
-	// Read the data
-	data := make([]byte, readSize)
-	n, err := io.ReadFull(file, data)
-	if err != nil && err != io.ErrUnexpectedEOF {
-		return
+// annotateLines extracts the structured fields of lines read from the log files
+func (s *Service) annotateLines(lines []LogLine) []LogLine {
+	for i := range lines {
+		s.fieldParser.annotate(&lines[i])
 	}
+	return lines
+}
 
-	// Write to buffer
-	if n > 0 {
-		buf.Write(data[:n])
+// ErrorsPerMinute returns the number of error (or worse) lines logged during the last minute
+func (s *Service) ErrorsPerMinute() int {
+	return s.errorLines.PerMinute()
+}
+
+// logFileFormat returns the on-disk format of log lines (service setting, then global, default raw)
+func (s *Service) logFileFormat() string {
+	if s.Config.LogFileFormat != "" && isValidLogFormat(s.Config.LogFileFormat) {
+		return s.Config.LogFileFormat
+	}
+	if s.global.LogFileFormat != "" && isValidLogFormat(s.global.LogFileFormat) {
+		return s.global.LogFileFormat
 	}
+	return LogFormatRaw
 }
 
-// readLogs reads from a pipe and writes to file, buffer, and broadcast
-func (s *Service) readLogs(pipe io.Reader, file *os.File, buf *CircularBuffer, broadcast *Broadcaster) {
-	scanner := bufio.NewScanner(pipe)
-	for scanner.Scan() {
-		line := scanner.Text() + "\n"
+// readLogs reads lines from a pipe and writes them to file, per-run file, buffer, broadcast and
+// the combined stream. origin provides the stream, run ID and PID of every line.
+func (s *Service) readLogs(pipe io.Reader, origin LogLine, file *LogFile, runLog *os.File, buf *CircularBuffer, broadcast *Broadcaster) {
+	format := s.logFileFormat()
+
+	err := newLineReader(pipe, s.Config.MaxLineBytes()).Run(func(text string) {
+		s.logMu.Lock()
+		defer s.logMu.Unlock()
+
+		// Files and sinks get the text without escape sequences (ansi: strip or render), the
+		// live line keeps the original text
+		logged := origin
+		logged.Time = time.Now()
+		logged.Text, _ = applyANSIMode(s.Config.ANSI, text)
+		logged.Offset = file.Offset()
+		s.fieldParser.annotate(&logged)
+		if logLevelRank(logged.Level) >= logLevelRank("error") {
+			s.errorLines.Add(logged.Time)
+		}
+		s.alerts.Check(logged)
+		formatted := formatLogLine(logged, format)
+
+		line := logged
+		line.Text = text
+		_, line.HTML = applyANSIMode(s.Config.ANSI, text)
 
 		// Write to file
 		if file != nil {
-			file.WriteString(line)
+			file.WriteString(formatted)
+		}
+
+		// Write to per-run file (shared by stdout and stderr)
+		if runLog != nil {
+			runLog.WriteString(formatted)
 		}
 
 		// Write to circular buffer
-		buf.Write([]byte(line))
+		line.Seq = buf.Write(line)
 
 		// Broadcast to subscribers
 		broadcast.Broadcast(line)
+
+		// Interleave with the other stream
+		s.writeCombined(line, logged, format)
+	})
+
+	// The pipe is closed by monitor once the process exited, which is not an error
+	if err != nil && !errors.Is(err, os.ErrClosed) {
+		s.mu.Lock()
+		s.logServiceEvent(fmt.Sprintf("Error reading %s: %v", origin.Stream, err))
+		s.mu.Unlock()
 	}
 }
 
 // monitor watches the process and handles restarts
-func (s *Service) monitor() {
+func (s *Service) monitor(readersDone <-chan struct{}, stdout, stderr *os.File) {
 	startTime := time.Now()
 	err := s.cmd.Wait()
 	duration := time.Since(startTime)
 
+	// Let the log readers drain the remaining output. Child processes that inherited
+	// the pipes may keep them open, so stop waiting after a grace period.
+	select {
+	case <-readersDone:
+	case <-time.After(logDrainTimeout):
+	}
+	stdout.Close()
+	stderr.Close()
+
 	// Get exit code
 	exitCode := 0
 	if err != nil {
@@ -639,12 +1130,34 @@ func (s *Service) monitor() {
 	s.lastError = err
 
 	// Log service exit BEFORE closing files
-	serviceType := "continuous"
-	if s.Config.IsScheduled() {
-		serviceType = "scheduled"
-	}
 	s.logServiceEvent(fmt.Sprintf("Service '%s' (%s) exited with code %d (duration: %v)",
-		s.Config.Name, serviceType, exitCode, duration.Round(time.Millisecond)))
+		s.Config.Name, s.Config.Kind(), exitCode, duration.Round(time.Millisecond)))
+	s.events.Publish(Event{Type: EventExited, Service: s.Config.Name, ExitCode: &exitCode})
+
+	// Finish the run record before the log files are closed
+	var finishedRun RunRecord
+	if run := s.currentRun; run != nil {
+		run.EndTime = startTime.Add(duration)
+		run.ExitCode = exitCode
+		run.Signal = exitSignal(err)
+		run.Duration = duration.Seconds()
+		run.StdoutEnd = s.stdoutFile.Offset()
+		run.StderrEnd = s.stderrFile.Offset()
+		if err != nil {
+			run.Error = err.Error()
+		}
+		select {
+		case <-s.stopChan:
+			run.Stopped = true
+		default:
+		}
+		if err := s.runHistory.Append(*run); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to record run for service %s: %v\n", s.Config.Name, err)
+		}
+		finishedRun = *run
+		s.currentRun = nil
+	}
+	s.succeeded = exitCode == 0 && !finishedRun.Stopped
 
 	s.closeLogFiles()
 
@@ -659,15 +1172,28 @@ func (s *Service) monitor() {
 
 	// Call failure callback (both on failure and success, so manager can reset state)
 	callback := s.failureCallback
+	exitCallback := s.exitCallback
 	consecutiveFailures := s.consecutiveFailures
+	runLogs := s.Config.RunLogs
 	s.mu.Unlock()
 
+	// Apply per-run log retention
+	if runLogs != nil {
+		if err := s.runHistory.PruneRunLogs(runLogs.Keep, runLogs.MaxAgeDuration()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to prune run logs for service %s: %v\n", s.Config.Name, err)
+		}
+	}
+
 	if callback != nil {
 		callback(s.Config.Name, consecutiveFailures, exitCode, err)
 	}
+	if exitCallback != nil {
+		exitCallback(s.Config.Name, finishedRun)
+	}
 
-	// Scheduled services don't auto-restart, let the scheduler handle it
-	if s.Config.IsScheduled() {
+	// Only continuous services auto-restart (the scheduler handles scheduled services,
+	// oneshot services run exactly once)
+	if !s.Config.IsContinuous() {
 		return
 	}
 
@@ -684,8 +1210,11 @@ func (s *Service) monitor() {
 	// prevent stale monitor goroutines from resurrecting services after re-enable.
 	s.mu.RLock()
 	failures := s.consecutiveFailures
+	audit := s.audit
 	s.mu.RUnlock()
 	if failures >= maxRestartAttempts {
+		audit.Record(AuditEntry{Action: AuditGiveUp, Service: s.Config.Name, Actor: ActorAuto,
+			Details: fmt.Sprintf("%d consecutive failures", failures)})
 		fmt.Fprintf(os.Stderr, "Service %s has failed %d consecutive times (limit: %d). Giving up on automatic restarts.\n",
 			s.Config.Name, failures, maxRestartAttempts)
 		fmt.Fprintf(os.Stderr, "Please check the service logs and manually restart when ready.\n")
@@ -709,5 +1238,9 @@ func (s *Service) monitor() {
 	}
 
 	// Attempt restart
-	_ = s.Start()
+	if err := s.StartRun(RunOptions{Trigger: TriggerRestart}); err == nil {
+		s.publish(Event{Type: EventRestarted})
+		audit.Record(AuditEntry{Action: AuditAutoRestart, Service: s.Config.Name, Actor: ActorAuto,
+			Details: fmt.Sprintf("exit code %d", exitCode)})
+	}
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestService_LogPaths(t *testing.T) {
+	t.Chdir(t.TempDir())
+	today := time.Now().Format("2006-01-02")
+
+	tests := []struct {
+		name       string
+		global     GlobalConfig
+		cfg        ServiceConfig
+		wantStdout string
+		wantStderr string
+		wantRunDir string
+	}{
+		{
+			name:       "defaults",
+			cfg:        ServiceConfig{Name: "web"},
+			wantStdout: filepath.Join("logs", "web-stdout.log"),
+			wantStderr: filepath.Join("logs", "web-stderr.log"),
+			wantRunDir: filepath.Join("logs", "web", "runs"),
+		},
+		{
+			name:       "log dir",
+			global:     GlobalConfig{LogDir: "/var/log/sm"},
+			cfg:        ServiceConfig{Name: "web"},
+			wantStdout: filepath.Join("/var/log/sm", "web-stdout.log"),
+			wantStderr: filepath.Join("/var/log/sm", "web-stderr.log"),
+			wantRunDir: filepath.Join("/var/log/sm", "web", "runs"),
+		},
+		{
+			name:       "templates",
+			global:     GlobalConfig{LogDir: "/var/log/sm"},
+			cfg:        ServiceConfig{Name: "web", StdoutPath: "{name}/{date}.out", StderrPath: "/srv/{name}.err"},
+			wantStdout: filepath.Join("/var/log/sm", "web", today+".out"),
+			wantStderr: "/srv/web.err",
+			wantRunDir: filepath.Join("/var/log/sm", "web", "runs"),
+		},
+		{
+			name:       "combined",
+			cfg:        ServiceConfig{Name: "web", Log: LogModeCombined},
+			wantStdout: filepath.Join("logs", "web.log"),
+			wantStderr: filepath.Join("logs", "web.log"),
+			wantRunDir: filepath.Join("logs", "web", "runs"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(tt.cfg, tt.global)
+			if got := svc.stdoutLogPath(); got != tt.wantStdout {
+				t.Errorf("stdout path = %q, want %q", got, tt.wantStdout)
+			}
+			if got := svc.stderrLogPath(); got != tt.wantStderr {
+				t.Errorf("stderr path = %q, want %q", got, tt.wantStderr)
+			}
+			if got := svc.runLogDir(); got != tt.wantRunDir {
+				t.Errorf("run log dir = %q, want %q", got, tt.wantRunDir)
+			}
+		})
+	}
+}
+
+func TestService_CombinedLogFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web", Log: LogModeCombined}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	svc.logServiceEvent("Starting")
+	svc.closeLogFiles()
+
+	data := readLogTail(svc.stdoutLogPath(), 1024)
+	if len(data) == 0 {
+		t.Fatal("Expected combined log to contain the event")
+	}
+	if got := strings.Count(string(data), "\n"); got != 1 {
+		t.Errorf("Expected the event to be written once to the combined log, got %d lines", got)
+	}
+}
+
+func TestService_CombinedStream(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web", Log: LogModeBoth, LogFileFormat: LogFormatJSON}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	svc.readLogs(strings.NewReader("one\n"), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.readLogs(strings.NewReader("two\n"), LogLine{Stream: "stderr"}, svc.stderrFile, nil, svc.stderrBuf, svc.stderrBroadcast)
+	svc.logServiceEvent("Stopping")
+	svc.readLogs(strings.NewReader("three\n"), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.closeLogFiles()
+
+	wantStreams := []string{"stdout", "stderr", "stdout", "stdout"}
+	lines := svc.GetCombinedBuffer()
+	if len(lines) != len(wantStreams) {
+		t.Fatalf("Expected %d combined lines, got %v", len(wantStreams), lines)
+	}
+	for i, line := range lines {
+		if line.Stream != wantStreams[i] {
+			t.Errorf("line %d: expected stream %s, got %+v", i, wantStreams[i], line)
+		}
+	}
+
+	// The combined file has the same lines and fills the buffer after a restart
+	restarted := NewService(svc.Config, GlobalConfig{})
+	reloaded := restarted.GetCombinedBuffer()
+	if len(reloaded) != len(lines) {
+		t.Fatalf("Expected %d reloaded lines from %s, got %v", len(lines), filepath.Join("logs", "web.log"), reloaded)
+	}
+	for i := range lines {
+		if reloaded[i].Stream != lines[i].Stream || reloaded[i].Text != lines[i].Text {
+			t.Errorf("line %d: expected %+v, got %+v", i, lines[i], reloaded[i])
+		}
+	}
+	if got := len(restarted.GetStderrBuffer()); got != 2 {
+		t.Errorf("Expected separate stderr log to keep 2 lines, got %d", got)
+	}
+}
+
+func TestService_ClearLogs(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "web", Log: LogModeBoth, LogRotation: &LogRotationConfig{MaxSize: "10B"}}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	defer svc.closeLogFiles()
+
+	svc.readLogs(strings.NewReader("first line\nsecond line\n"), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	svc.readLogs(strings.NewReader("error\n"), LogLine{Stream: "stderr"}, svc.stderrFile, nil, svc.stderrBuf, svc.stderrBroadcast)
+	end := svc.stdoutFile.Offset()
+
+	// Clearing stdout keeps stderr, also in the combined buffer
+	if err := svc.ClearLogs("stdout"); err != nil {
+		t.Fatalf("ClearLogs failed: %v", err)
+	}
+	if len(svc.GetStdoutBuffer()) != 0 || len(svc.GetStderrBuffer()) != 1 {
+		t.Errorf("Expected only the stdout buffer to be cleared")
+	}
+	if combined := svc.GetCombinedBuffer(); len(combined) != 1 || combined[0].Stream != "stderr" {
+		t.Errorf("Expected stderr lines to stay in the combined buffer, got %v", combined)
+	}
+	if segments := LogSegments(svc.stdoutLogPath()); len(segments) != 1 || len(readLogTail(svc.stdoutLogPath(), 1024)) != 0 {
+		t.Errorf("Expected an empty stdout log without rotated segments, got %v", segments)
+	}
+
+	// Writing continues after the old end, so earlier offsets stay unambiguous
+	svc.readLogs(strings.NewReader("after\n"), LogLine{Stream: "stdout"}, svc.stdoutFile, nil, svc.stdoutBuf, svc.stdoutBroadcast)
+	if lines := svc.GetStdoutBuffer(); len(lines) != 1 || lines[0].Offset != end {
+		t.Errorf("Expected the new line at offset %d, got %v", end, lines)
+	}
+	if data := string(readLogTail(svc.stdoutLogPath(), 1024)); data != "after\n" {
+		t.Errorf("Expected only the new line in the file, got %q", data)
+	}
+
+	if err := svc.ClearLogs(""); err != nil {
+		t.Fatalf("ClearLogs failed: %v", err)
+	}
+	if len(svc.GetStdoutBuffer())+len(svc.GetStderrBuffer())+len(svc.GetCombinedBuffer()) != 0 {
+		t.Errorf("Expected all buffers to be empty")
+	}
+	for _, path := range svc.LogFilePaths() {
+		if data := readLogTail(path, 1024); len(data) != 0 {
+			t.Errorf("Expected %s to be empty, got %q", path, data)
+		}
+	}
+}
+
+func TestService_ClearLogsNotRunning(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	svc := NewService(ServiceConfig{Name: "job"}, GlobalConfig{})
+	if err := svc.openLogFiles(); err != nil {
+		t.Fatalf("openLogFiles failed: %v", err)
+	}
+	svc.logServiceEvent("Finished")
+	svc.closeLogFiles()
+
+	if err := svc.ClearLogs(""); err != nil {
+		t.Fatalf("ClearLogs failed: %v", err)
+	}
+	restarted := NewService(svc.Config, GlobalConfig{})
+	if len(restarted.GetStdoutBuffer()) != 0 || len(restarted.GetStderrBuffer()) != 0 {
+		t.Errorf("Expected no history to be loaded after clearing")
+	}
+}
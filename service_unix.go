@@ -16,6 +16,18 @@ func configureCmdWindows(cmd *exec.Cmd) {
 
 // platformStartProcess is defined in platform_unix.go
 
+// exitSignal returns the name of the signal that terminated the process, if any
+func exitSignal(err error) string {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return ""
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
+
 // gracefulStop attempts to gracefully stop a service process and its children.
 // It sends SIGTERM to the process group first, waits for the timeout, then sends SIGKILL if needed.
 func gracefulStop(s *Service, timeout time.Duration) error {
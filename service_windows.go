@@ -21,6 +21,11 @@ func configureCmdWindows(cmd *exec.Cmd) {
 
 // platformStartProcess is defined in platform_windows.go
 
+// exitSignal always returns an empty string on Windows (processes are not terminated by signals)
+func exitSignal(err error) string {
+	return ""
+}
+
 // gracefulStop attempts to gracefully stop a service process and its entire process tree on Windows
 func gracefulStop(s *Service, timeout time.Duration) error {
 	if s.cmd == nil || s.cmd.Process == nil {
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statusStreamInterval is how often the status event stream checks the services for changes
+const statusStreamInterval = time.Second
+
+// sseKeepAlive is how often an idle event stream sends a comment, so proxies keep the connection open
+const sseKeepAlive = 30 * time.Second
+
+// sseWriter writes Server-Sent Events (text/event-stream) to an HTTP response
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEWriter starts an event stream response. Fails if the response can't be flushed.
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Don't let nginx buffer the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+// Event sends an event. An empty id or event type is omitted (the client keeps its last event ID
+// and dispatches a "message" event); multi-line data is sent as several data fields.
+func (sse *sseWriter) Event(id, event, data string) error {
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: " + id + "\n")
+	}
+	if event != "" {
+		sb.WriteString("event: " + event + "\n")
+	}
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+
+	if _, err := sse.w.Write([]byte(sb.String())); err != nil {
+		return err
+	}
+	sse.flusher.Flush()
+	return nil
+}
+
+// JSONEvent sends an event with a JSON-encoded value as data
+func (sse *sseWriter) JSONEvent(id, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return sse.Event(id, event, string(data))
+}
+
+// KeepAlive sends a comment line, which clients ignore
+func (sse *sseWriter) KeepAlive() error {
+	if _, err := sse.w.Write([]byte(": keep-alive\n\n")); err != nil {
+		return err
+	}
+	sse.flusher.Flush()
+	return nil
+}
+
+// sseLogSender sends log lines as events: the event ID is the line's seq (so a reconnecting
+// EventSource resumes with Last-Event-ID) and dropped-lines markers are "dropped" events
+type sseLogSender struct {
+	sse        *sseWriter
+	jsonFormat bool
+}
+
+func (s *sseLogSender) History(lines []LogLine) error {
+	for _, line := range lines {
+		if err := s.Line(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sseLogSender) Line(line LogLine) error {
+	id, event := "", ""
+	if line.Dropped > 0 {
+		event = "dropped"
+	} else if line.Seq > 0 {
+		id = strconv.FormatInt(line.Seq, 10)
+	}
+
+	if s.jsonFormat {
+		return s.sse.JSONEvent(id, event, line)
+	}
+	return s.sse.Event(id, event, line.Text)
+}
+
+func (s *sseLogSender) KeepAlive() error {
+	return s.sse.KeepAlive()
+}
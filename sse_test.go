@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSSEWriter_Event(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse, err := newSSEWriter(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected event stream content type, got %q", ct)
+	}
+
+	sse.Event("7", "status", "first\nsecond")
+	sse.Event("", "", "plain")
+	sse.KeepAlive()
+
+	expected := "id: 7\nevent: status\ndata: first\ndata: second\n\n" +
+		"data: plain\n\n" +
+		": keep-alive\n\n"
+	if rec.Body.String() != expected {
+		t.Errorf("Unexpected stream:\n%q\nexpected:\n%q", rec.Body.String(), expected)
+	}
+}
+
+func TestSSELogSender_IDsAndMarkers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse, _ := newSSEWriter(rec)
+	sender := &sseLogSender{sse: sse}
+
+	sender.History([]LogLine{{Seq: 41, Text: "one"}, {Seq: 42, Text: "two"}})
+	sender.Line(droppedMarker(LogLine{}, 3))
+
+	// The marker has no ID, so a reconnect resumes after the last real line
+	expected := "id: 41\ndata: one\n\n" +
+		"id: 42\ndata: two\n\n" +
+		"event: dropped\ndata: [service-manager] 3 lines dropped\n\n"
+	if rec.Body.String() != expected {
+		t.Errorf("Unexpected stream:\n%q\nexpected:\n%q", rec.Body.String(), expected)
+	}
+}
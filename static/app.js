@@ -1,18 +1,69 @@
 let selectedService = null;
 let currentStream = 'stdout';
 let logWebSocket = null;
+let logReconnectTimer = null;
+let lastLogSeq = null; // Sequence number of the last received line, to resume after a disconnect
+let logLines = [];
+let olderLogsExhausted = false;
+let oldestLogOffset = null; // Offset from which older lines are loaded (null = the first shown line)
 let refreshInterval = null;
 let lastServicesSnapshot = null;
+let serviceEvents = null;
+let servicesRefreshTimer = null;
+let selectedServiceStatus = null; // Last status of the selected service and when it was received
+let selectedServiceStatusTime = 0;
 
 // Initialize
 document.addEventListener('DOMContentLoaded', () => {
+    loadCurrentUser();
     loadServices();
     setupEventListeners();
+    connectServiceEvents();
 
-    // Refresh service list every 2 seconds
-    refreshInterval = setInterval(loadServices, 2000);
+    // Tick the uptime and next run locally; the error rate changes without events, so refresh
+    // the list occasionally
+    setInterval(tickServiceStatus, 1000);
+    refreshInterval = setInterval(loadServices, 30000);
 });
 
+// Hide the actions the signed-in user's role doesn't allow (the server enforces them anyway)
+async function loadCurrentUser() {
+    try {
+        const response = await fetch('/api/me');
+        if (!response.ok) {
+            return;
+        }
+        const user = await response.json();
+        document.body.classList.add(`role-${user.role}`);
+    } catch (error) {
+        console.error('Failed to load current user:', error);
+    }
+}
+
+// Reload the services whenever the server reports a state change
+function connectServiceEvents() {
+    serviceEvents = new EventSource('/api/events');
+
+    // Also after a reconnect, to pick up changes that happened while disconnected
+    serviceEvents.onopen = () => scheduleServicesRefresh();
+    serviceEvents.onmessage = () => scheduleServicesRefresh();
+}
+
+// Reload the services once for a burst of events (e.g. a config reload)
+function scheduleServicesRefresh() {
+    clearTimeout(servicesRefreshTimer);
+    servicesRefreshTimer = setTimeout(loadServices, 100);
+}
+
+// Re-render the status of the selected service with its uptime advanced since it was received
+function tickServiceStatus() {
+    if (!selectedServiceStatus || selectedServiceStatus.name !== selectedService) {
+        return;
+    }
+    const elapsed = (Date.now() - selectedServiceStatusTime) / 1000;
+    updateServiceStatus({ ...selectedServiceStatus, uptime: selectedServiceStatus.uptime + elapsed }, false);
+}
+
 // Setup event listeners
 function setupEventListeners() {
     document.getElementById('createServiceBtn').addEventListener('click', showCreateView);
@@ -26,7 +77,10 @@ function setupEventListeners() {
     document.getElementById('startBtn').addEventListener('click', () => controlService('start'));
     document.getElementById('stopBtn').addEventListener('click', () => controlService('stop'));
     document.getElementById('restartBtn').addEventListener('click', () => controlService('restart'));
-    document.getElementById('runNowBtn').addEventListener('click', handleRunNow);
+    document.getElementById('runNowBtn').addEventListener('click', () => handleRunNow());
+    document.getElementById('runWithBtn').addEventListener('click', showRunForm);
+    document.getElementById('cancelRunBtn').addEventListener('click', hideRunForm);
+    document.getElementById('runParamsForm').addEventListener('submit', handleRunWithParams);
     document.getElementById('deleteBtn').addEventListener('click', handleDeleteService);
 
     // Log tabs
@@ -41,6 +95,23 @@ function setupEventListeners() {
         });
     });
 
+    // Log display options
+    document.getElementById('timestampsCheckbox').addEventListener('change', renderLogLines);
+    document.getElementById('prettyCheckbox').addEventListener('change', renderLogLines);
+    document.getElementById('logLevel').addEventListener('change', () => {
+        if (selectedService) {
+            connectLogStream(selectedService, currentStream);
+        }
+    });
+    document.getElementById('loadOlderBtn').addEventListener('click', loadOlderLogs);
+    document.getElementById('downloadLogBtn').addEventListener('click', downloadLog);
+    document.getElementById('clearLogBtn').addEventListener('click', clearLogs);
+    document.getElementById('logSince').addEventListener('change', () => {
+        if (selectedService) {
+            connectLogStream(selectedService, currentStream);
+        }
+    });
+
     // Working directory change listeners for .env detection
     let editWorkdirDebounce;
     document.getElementById('editWorkdir').addEventListener('input', (e) => {
@@ -134,7 +205,9 @@ async function loadServices() {
             name: s.name,
             running: s.running,
             enabled: s.enabled,
-            schedule: s.schedule
+            schedule: s.schedule,
+            type: s.type,
+            pending: s.pending
         })));
 
         if (currentSnapshot !== lastServicesSnapshot) {
@@ -176,12 +249,17 @@ function renderServiceList(services) {
             item.classList.add('continuous');
         }
 
-        // Show clock icon for scheduled services, status dot for continuous
+        // Show clock icon for scheduled services, play icon for oneshot/manual, status dot for continuous
         if (isScheduledService(service)) {
             const clock = document.createElement('div');
             clock.className = 'clock-icon';
             clock.textContent = '⏰';
             item.appendChild(clock);
+        } else if (isOneshotService(service) || isManualService(service)) {
+            const icon = document.createElement('div');
+            icon.className = 'clock-icon';
+            icon.textContent = '▶';
+            item.appendChild(icon);
         } else {
             const dot = document.createElement('div');
             // Determine dot class based on enabled + running state
@@ -248,25 +326,29 @@ function showServiceView(service) {
     const stopBtn = document.getElementById('stopBtn');
     const restartBtn = document.getElementById('restartBtn');
     const runNowBtn = document.getElementById('runNowBtn');
+    const runWithBtn = document.getElementById('runWithBtn');
 
     // Set checkbox state
     enabledCheckbox.checked = service.enabled !== false;
 
-    if (isScheduledService(service)) {
-        // Scheduled service: hide start/stop/restart, show Run Now
+    if (isJobService(service)) {
+        // Scheduled/oneshot/manual service: hide start/stop/restart, show Run Now
         startBtn.style.display = 'none';
         stopBtn.style.display = 'none';
         restartBtn.style.display = 'none';
         runNowBtn.style.display = 'inline-block';
+        runWithBtn.style.display = 'inline-block';
 
         // Disable Run Now if already running or service is disabled
         runNowBtn.disabled = service.running || service.enabled === false;
+        runWithBtn.disabled = runNowBtn.disabled;
     } else {
         // Continuous service: show Start/Stop/Restart buttons
         startBtn.style.display = 'inline-block';
         stopBtn.style.display = 'inline-block';
         restartBtn.style.display = 'inline-block';
         runNowBtn.style.display = 'none';
+        runWithBtn.style.display = 'none';
 
         // Enable/disable buttons based on running state only
         // (Allow runtime control regardless of enabled flag)
@@ -281,16 +363,65 @@ function showServiceView(service) {
         }
     }
 
-    // Hide edit form if visible
+    // Hide edit and run forms if visible
     hideEditForm();
+    hideRunForm();
 }
 
 // Update service status display
-function updateServiceStatus(service) {
+function updateServiceStatus(service, received = true) {
+    if (received) {
+        selectedServiceStatus = service;
+        selectedServiceStatusTime = Date.now();
+    }
+
     const badge = document.getElementById('statusBadge');
     const stats = document.getElementById('serviceStats');
 
-    if (isScheduledService(service)) {
+    if (isOneshotService(service) || isManualService(service)) {
+        // Oneshot/manual service status
+        if (service.running) {
+            badge.textContent = 'Running';
+            badge.className = 'status-badge running';
+        } else if (service.pending) {
+            badge.textContent = 'Waiting';
+            badge.className = 'status-badge scheduled';
+        } else if (!service.lastRunTime) {
+            badge.textContent = service.enabled !== false ? 'Not Run' : 'Disabled';
+            badge.className = 'status-badge stopped';
+        } else {
+            badge.textContent = service.lastExitCode === 0 ? 'Completed' : 'Failed';
+            badge.className = `status-badge ${service.lastExitCode === 0 ? 'running' : 'stopped'}`;
+        }
+
+        const lastRun = service.lastRunTime ? formatLastRun(service.lastRunTime) : 'Never';
+        const lastExitCode = service.lastRunTime ? service.lastExitCode : 'N/A';
+        const lastDuration = service.lastDuration ? formatDuration(service.lastDuration) : 'N/A';
+        const waitingFor = service.after && service.after.length > 0 ? escapeHtml(service.after.join(', ')) : 'None';
+
+        stats.innerHTML = `
+            <div class="stat-item">
+                <div class="stat-label">Type</div>
+                <div class="stat-value">${isManualService(service) ? 'Manual' : 'One-shot'}</div>
+            </div>
+            <div class="stat-item">
+                <div class="stat-label">After</div>
+                <div class="stat-value">${waitingFor}</div>
+            </div>${formatWatchStat(service)}${formatErrorRateStat(service)}
+            <div class="stat-item">
+                <div class="stat-label">Last Run</div>
+                <div class="stat-value">${lastRun}</div>
+            </div>
+            <div class="stat-item">
+                <div class="stat-label">Last Exit Code</div>
+                <div class="stat-value">${lastExitCode}</div>
+            </div>
+            <div class="stat-item">
+                <div class="stat-label">Last Duration</div>
+                <div class="stat-value">${lastDuration}</div>
+            </div>
+        `;
+    } else if (isScheduledService(service)) {
         // Scheduled service status
         if (service.running) {
             badge.textContent = 'Running';
@@ -309,12 +440,12 @@ function updateServiceStatus(service) {
         stats.innerHTML = `
             <div class="stat-item">
                 <div class="stat-label">Schedule</div>
-                <div class="stat-value">${service.schedule || 'N/A'}</div>
+                <div class="stat-value">${service.schedule ? escapeHtml(service.schedule) : 'N/A'}</div>
             </div>
             <div class="stat-item">
                 <div class="stat-label">Next Run</div>
                 <div class="stat-value">${nextRun}</div>
-            </div>
+            </div>${formatWatchStat(service)}${formatErrorRateStat(service)}
             <div class="stat-item">
                 <div class="stat-label">Last Run</div>
                 <div class="stat-value">${lastRun}</div>
@@ -353,7 +484,7 @@ function updateServiceStatus(service) {
             <div class="stat-item">
                 <div class="stat-label">Auto-start</div>
                 <div class="stat-value">${enabled}</div>
-            </div>
+            </div>${formatErrorRateStat(service)}
         `;
     }
 }
@@ -374,26 +505,70 @@ function formatUptime(seconds) {
 // Connect to log stream via WebSocket
 function connectLogStream(serviceName, stream) {
     // Close existing connection
-    if (logWebSocket) {
-        logWebSocket.close();
-    }
+    closeLogStream();
 
     const logContent = document.getElementById('logContent');
     const logViewer = logContent.parentElement;
     logContent.textContent = '';
+    logLines = [];
+    lastLogSeq = null;
+    olderLogsExhausted = false;
+    oldestLogOffset = null;
+    updateLoadOlderButton();
 
     // Reset scroll to bottom when first connecting
     logViewer.scrollTop = logViewer.scrollHeight;
 
+    openLogStream(serviceName, stream);
+}
+
+// Close the log stream without reconnecting
+function closeLogStream() {
+    clearTimeout(logReconnectTimer);
+    if (logWebSocket) {
+        const ws = logWebSocket;
+        logWebSocket = null;
+        ws.close();
+    }
+}
+
+// Open the log WebSocket, resuming after the last received line if there is one
+function openLogStream(serviceName, stream) {
+    const logContent = document.getElementById('logContent');
+    const logViewer = logContent.parentElement;
+
+    // Lines come as JSON records so timestamps can be shown
+    const params = new URLSearchParams({ format: 'json' });
+    const since = document.getElementById('logSince').value;
+    if (since) {
+        params.set('since', new Date(since).toISOString());
+    }
+    const level = document.getElementById('logLevel').value;
+    if (level) {
+        params.set('level', level);
+    }
+    if (lastLogSeq !== null) {
+        params.set('after', lastLogSeq);
+    }
+
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const url = `${protocol}//${window.location.host}/api/services/${serviceName}/logs/${stream}`;
+    const url = `${protocol}//${window.location.host}/api/services/${serviceName}/logs/${stream}?${params}`;
 
-    logWebSocket = new WebSocket(url);
+    const ws = new WebSocket(url);
+    logWebSocket = ws;
 
-    logWebSocket.onmessage = (event) => {
+    ws.onmessage = (event) => {
         const wasAtBottom = logViewer.scrollHeight - logViewer.scrollTop - logViewer.clientHeight < 10;
 
-        logContent.textContent += event.data;
+        const line = JSON.parse(event.data);
+        if (line.seq) {
+            lastLogSeq = line.seq;
+        }
+        logLines.push(line);
+        appendLogLine(logContent, line);
+        if (logLines.length === 1) {
+            updateLoadOlderButton();
+        }
 
         // Only auto-scroll if already at bottom
         if (wasAtBottom) {
@@ -401,9 +576,182 @@ function connectLogStream(serviceName, stream) {
         }
     };
 
-    logWebSocket.onerror = (error) => {
+    ws.onerror = (error) => {
         console.error('WebSocket error:', error);
     };
+
+    // Reconnect after an unexpected disconnect (e.g. server restart), continuing after the last line
+    ws.onclose = () => {
+        if (ws !== logWebSocket) {
+            return;
+        }
+        logWebSocket = null;
+        logReconnectTimer = setTimeout(() => {
+            if (selectedService === serviceName && currentStream === stream) {
+                openLogStream(serviceName, stream);
+            }
+        }, 2000);
+    };
+}
+
+// Show "Load older lines" while the oldest shown line is not at the start of the log file
+function updateLoadOlderButton() {
+    const since = document.getElementById('logSince').value;
+    const hasOlder = firstLogOffset() > 0;
+    document.getElementById('loadOlderBtn').hidden = olderLogsExhausted || since !== '' || !hasOlder;
+}
+
+// Offset of the oldest line read so far (lines hidden by the level filter included)
+function firstLogOffset() {
+    if (oldestLogOffset !== null) {
+        return oldestLogOffset;
+    }
+    return logLines.length > 0 ? (logLines[0].offset || 0) : 0;
+}
+
+// Prepend older lines from the log file before the oldest shown line
+async function loadOlderLogs() {
+    if (!selectedService || logLines.length === 0) return;
+
+    const logViewer = document.getElementById('logContent').parentElement;
+    const params = new URLSearchParams({ before: firstLogOffset(), lines: 200 });
+    const level = document.getElementById('logLevel').value;
+    if (level) {
+        params.set('level', level);
+    }
+
+    try {
+        const response = await fetch(`/api/services/${selectedService}/logs/${currentStream}/range?${params}`);
+        if (!response.ok) {
+            // e.g. the combined stream without a combined log file
+            olderLogsExhausted = true;
+            updateLoadOlderButton();
+            return;
+        }
+
+        const range = await response.json();
+        oldestLogOffset = range.start;
+        if (range.start <= range.logStart) {
+            olderLogsExhausted = true;
+        }
+
+        // Keep the visible lines in place while prepending
+        const previousHeight = logViewer.scrollHeight;
+        logLines = range.lines.concat(logLines);
+        renderLogLines();
+        logViewer.scrollTop += logViewer.scrollHeight - previousHeight;
+        updateLoadOlderButton();
+    } catch (error) {
+        console.error('Error loading older logs:', error);
+    }
+}
+
+// Download the full log of the current stream (limited by the "Since" filter)
+function downloadLog() {
+    if (!selectedService) return;
+
+    const params = new URLSearchParams({ gzip: 'true' });
+    const since = document.getElementById('logSince').value;
+    if (since) {
+        params.set('since', new Date(since).toISOString());
+    }
+    window.location.href = `/api/services/${selectedService}/logs/${currentStream}/download?${params}`;
+}
+
+// Truncate all log files of the service and start with an empty log view
+async function clearLogs() {
+    if (!selectedService) return;
+    if (!confirm(`Clear all logs of "${selectedService}"? The log files are truncated and rotated files deleted.`)) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`/api/services/${selectedService}/logs`, { method: 'DELETE' });
+        if (!response.ok) {
+            alert(`Failed to clear logs: ${await response.text()}`);
+            return;
+        }
+        connectLogStream(selectedService, currentStream);
+    } catch (error) {
+        console.error('Error clearing logs:', error);
+        alert('Failed to clear logs');
+    }
+}
+
+// Format a log line for display (optionally prefixed with its timestamp)
+function formatLogLine(line) {
+    return logLinePrefix(line) + logLineText(line) + '\n';
+}
+
+// Text of a log line, or level and message of structured (JSON/logfmt) lines in the pretty view
+function logLineText(line) {
+    const pretty = document.getElementById('prettyCheckbox').checked;
+    if (pretty && (line.level || line.msg)) {
+        return `${(line.level || '-').toUpperCase().padEnd(5)} ${line.msg || line.text}`;
+    }
+    return line.text;
+}
+
+// Timestamp shown before a log line (if enabled)
+function logLinePrefix(line) {
+    const showTimestamps = document.getElementById('timestampsCheckbox').checked;
+    if (!showTimestamps) {
+        return '';
+    }
+
+    // Lines loaded from raw log files have no timestamp
+    const time = line.time && !line.time.startsWith('0001-') ? new Date(line.time).toLocaleString() : '-';
+    return `[${time}] `;
+}
+
+// CSS class of a log line: level colors, and stderr lines highlighted in the combined stream
+function logLineClass(line) {
+    if (line.dropped) {
+        return 'log-dropped';
+    }
+    if (line.level === 'error' || line.level === 'fatal') {
+        return 'log-level-error';
+    }
+    if (line.level === 'warn') {
+        return 'log-level-warn';
+    }
+    if (currentStream === 'combined' && line.stream === 'stderr') {
+        return 'log-line-stderr';
+    }
+    return '';
+}
+
+// Append a log line to the viewer
+function appendLogLine(logContent, line) {
+    const className = logLineClass(line);
+
+    // Colors rendered by the server (ansi: render); the text in it is already escaped
+    if (line.html && logLineText(line) === line.text) {
+        const span = document.createElement('span');
+        span.className = className;
+        const content = document.createElement('span');
+        content.innerHTML = line.html;
+        span.append(logLinePrefix(line), content, '\n');
+        logContent.appendChild(span);
+        return;
+    }
+
+    const text = formatLogLine(line);
+    if (className) {
+        const span = document.createElement('span');
+        span.className = className;
+        span.textContent = text;
+        logContent.appendChild(span);
+    } else {
+        logContent.appendChild(document.createTextNode(text));
+    }
+}
+
+// Re-render all received log lines (e.g. after toggling timestamps)
+function renderLogLines() {
+    const logContent = document.getElementById('logContent');
+    logContent.textContent = '';
+    logLines.forEach(line => appendLogLine(logContent, line));
 }
 
 // Control service (start/stop/restart)
@@ -459,13 +807,15 @@ async function handleCreateService(e) {
     const workdir = document.getElementById('createWorkdir').value;
     const envText = document.getElementById('createEnv').value;
     const schedule = document.getElementById('createSchedule').value;
+    const type = document.getElementById('createType').value;
 
     const service = {
         name,
         command,
         workdir: workdir || undefined,
         env_raw: envText.trim() || undefined,  // Send raw text for godotenv parsing
-        schedule: schedule || undefined
+        schedule: schedule || undefined,
+        type: type || undefined
     };
 
     try {
@@ -495,6 +845,8 @@ async function handleCreateService(e) {
 async function showEditForm() {
     if (!selectedService) return;
 
+    hideRunForm();
+
     try {
         const response = await fetch(`/api/services/${selectedService}`);
         const service = await response.json();
@@ -511,8 +863,9 @@ async function showEditForm() {
         }
         document.getElementById('editEnv').value = envLines.join('\n');
 
-        // Set the schedule field
+        // Set the schedule and type fields
         document.getElementById('editSchedule').value = service.schedule || '';
+        document.getElementById('editType').value = service.type || '';
 
         // Store enabled state to preserve it during update
         document.getElementById('editForm').dataset.enabled = JSON.stringify(service.enabled);
@@ -545,6 +898,7 @@ async function handleUpdateService(e) {
     const workdir = document.getElementById('editWorkdir').value;
     const envText = document.getElementById('editEnv').value;
     const schedule = document.getElementById('editSchedule').value;
+    const type = document.getElementById('editType').value;
 
     // Retrieve preserved enabled value
     const editForm = document.getElementById('editForm');
@@ -556,7 +910,8 @@ async function handleUpdateService(e) {
         workdir: workdir || undefined,
         env_raw: envText.trim() || undefined,  // Send raw text for godotenv parsing
         enabled: enabledValue,
-        schedule: schedule || undefined
+        schedule: schedule || undefined,
+        type: type || undefined
     };
 
     try {
@@ -600,10 +955,7 @@ async function handleDeleteService() {
             document.getElementById('serviceView').style.display = 'none';
             document.getElementById('welcomeView').style.display = 'block';
 
-            if (logWebSocket) {
-                logWebSocket.close();
-                logWebSocket = null;
-            }
+            closeLogStream();
 
             await loadServices();
         } else {
@@ -621,6 +973,55 @@ function isScheduledService(service) {
     return service.schedule !== undefined && service.schedule !== null && service.schedule !== '';
 }
 
+// Helper: Check if service is a oneshot service
+function isOneshotService(service) {
+    return service.type === 'oneshot';
+}
+
+// Helper: Check if service is a manual (run on demand only) service
+function isManualService(service) {
+    return service.type === 'manual';
+}
+
+// Helper: Check if service runs to completion (scheduled, oneshot or manual)
+function isJobService(service) {
+    return isScheduledService(service) || isOneshotService(service) || isManualService(service);
+}
+
+// Escape text for use in HTML markup (configuration values are shown to all users)
+function escapeHtml(text) {
+    return String(text)
+        .replace(/&/g, '&amp;')
+        .replace(/</g, '&lt;')
+        .replace(/>/g, '&gt;')
+        .replace(/"/g, '&quot;')
+        .replace(/'/g, '&#39;');
+}
+
+// Format the watched paths stat item (empty if the service has no file-change trigger)
+function formatWatchStat(service) {
+    if (!service.watch || service.watch.length === 0) {
+        return '';
+    }
+    return `
+            <div class="stat-item">
+                <div class="stat-label">Watching</div>
+                <div class="stat-value">${escapeHtml(service.watch.join(', '))}</div>
+            </div>`;
+}
+
+// Format the errors per minute of services with structured logs
+function formatErrorRateStat(service) {
+    if (!service.errorsPerMinute && (!service.logFormat || service.logFormat === 'text')) {
+        return '';
+    }
+    return `
+            <div class="stat-item">
+                <div class="stat-label">Errors/min</div>
+                <div class="stat-value">${service.errorsPerMinute || 0}</div>
+            </div>`;
+}
+
 // Format next run time with relative duration
 function formatNextRun(nextRunTime) {
     const next = new Date(nextRunTime);
@@ -699,22 +1100,27 @@ function formatDuration(ms) {
     return `${hours}h ${remainingMinutes}m`;
 }
 
-// Handle Run Now button
-async function handleRunNow() {
+// Handle Run Now button (params is an optional run-now request body)
+async function handleRunNow(params) {
     if (!selectedService) return;
 
     try {
-        const response = await fetch(`/api/services/${selectedService}/run-now`, {
-            method: 'POST'
-        });
+        const options = { method: 'POST' };
+        if (params) {
+            options.headers = { 'Content-Type': 'application/json' };
+            options.body = JSON.stringify(params);
+        }
+        const response = await fetch(`/api/services/${selectedService}/run-now`, options);
 
         if (response.ok) {
+            hideRunForm();
             // Refresh immediately
             setTimeout(() => selectService(selectedService), 200);
         } else if (response.status === 409) {
             alert('Service is already running');
         } else {
-            alert('Failed to run service');
+            const error = await response.text();
+            alert(`Failed to run service: ${error}`);
         }
     } catch (error) {
         console.error('Failed to run service:', error);
@@ -722,6 +1128,27 @@ async function handleRunNow() {
     }
 }
 
+// Show run parameters form
+function showRunForm() {
+    hideEditForm();
+    document.getElementById('runForm').style.display = 'block';
+}
+
+// Hide run parameters form
+function hideRunForm() {
+    document.getElementById('runForm').style.display = 'none';
+}
+
+// Handle run with parameters
+async function handleRunWithParams(e) {
+    e.preventDefault();
+
+    await handleRunNow({
+        args_raw: document.getElementById('runArgs').value,
+        env_raw: document.getElementById('runEnv').value
+    });
+}
+
 // Handle enabled checkbox change
 async function handleEnabledChange() {
     if (!selectedService) return;
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// User roles (users), each with the permissions of the previous ones
+const (
+	RoleViewer   = "viewer"   // Status, logs and run history (default)
+	RoleOperator = "operator" // Also start, stop, restart, run now (without overrides), enable/disable and clear logs
+	RoleAdmin    = "admin"    // Also create, update and delete services, audit log and log archive
+)
+
+// roleRank orders the roles by their permissions
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// User is an account that can sign in to the web UI and API
+type User struct {
+	Name     string
+	Role     string
+	password string
+	services []string // Name patterns of the services the user can access
+	labels   []string // Labels of the services the user can access
+}
+
+// anonymousUser makes all requests when authentication is disabled
+var anonymousUser = &User{Name: "anonymous", Role: RoleAdmin}
+
+// HasRole reports whether the user has at least the permissions of role
+func (u *User) HasRole(role string) bool {
+	return roleRank[u.Role] >= roleRank[role]
+}
+
+// Restricted reports whether the user can only access some services
+func (u *User) Restricted() bool {
+	return len(u.services) > 0 || len(u.labels) > 0
+}
+
+// CanAccess reports whether the user was granted access to a service. Users without
+// services and labels grants can access all services.
+func (u *User) CanAccess(cfg ServiceConfig) bool {
+	if !u.Restricted() {
+		return true
+	}
+	for _, pattern := range u.services {
+		if ok, _ := path.Match(pattern, cfg.Name); ok {
+			return true
+		}
+	}
+	for _, label := range cfg.Labels {
+		if slices.Contains(u.labels, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadUsers returns the accounts of the users section plus the authorization setting (an
+// admin account). Invalid entries are logged and skipped. Returns nil if authentication is
+// disabled.
+func loadUsers(config GlobalConfig) map[string]*User {
+	users := make(map[string]*User)
+
+	if config.Authorization != "" {
+		// "username:password" or just a password (empty username)
+		user := &User{Role: RoleAdmin, password: config.Authorization}
+		if idx := strings.Index(config.Authorization, ":"); idx > 0 {
+			user.Name = config.Authorization[:idx]
+			user.password = config.Authorization[idx+1:]
+		}
+		users[user.Name] = user
+	}
+
+	for _, cfg := range config.Users {
+		if cfg.Name == "" || cfg.Password == "" {
+			fmt.Fprintf(os.Stderr, "Ignoring user %q: name and password are required\n", cfg.Name)
+			continue
+		}
+		if _, exists := users[cfg.Name]; exists {
+			fmt.Fprintf(os.Stderr, "Ignoring duplicate user %q\n", cfg.Name)
+			continue
+		}
+		role := cfg.Role
+		if role == "" {
+			role = RoleViewer
+		} else if _, ok := roleRank[role]; !ok {
+			fmt.Fprintf(os.Stderr, "User %q has invalid role %q, using %s\n", cfg.Name, cfg.Role, RoleViewer)
+			role = RoleViewer
+		}
+		users[cfg.Name] = &User{
+			Name:     cfg.Name,
+			Role:     role,
+			password: cfg.Password,
+			services: cfg.Services,
+			labels:   cfg.Labels,
+		}
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+	return users
+}
+
+// authenticate returns the user with the given credentials, or nil
+func (s *Server) authenticate(username, password string) *User {
+	user, ok := s.users[username]
+	if !ok || subtle.ConstantTimeCompare([]byte(user.password), []byte(password)) != 1 {
+		return nil
+	}
+	return user
+}
+
+type userContextKey struct{}
+
+// requestUser returns the user that made a request
+func requestUser(r *http.Request) *User {
+	if user, ok := r.Context().Value(userContextKey{}).(*User); ok {
+		return user
+	}
+	return anonymousUser
+}
+
+// withUser returns the request with the authenticated user attached
+func withUser(r *http.Request, user *User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userContextKey{}, user))
+}
+
+// canAccessService reports whether the user can access a service. Services that don't exist
+// (e.g. deleted ones) are checked by name.
+func (s *Server) canAccessService(user *User, name string) bool {
+	if svc, err := s.serviceManager.GetService(name); err == nil {
+		return user.CanAccess(svc.Config)
+	}
+	return user.CanAccess(ServiceConfig{Name: name})
+}
+
+// require wraps a handler that needs at least the given role and, for routes of a service
+// ({name}), access to that service
+func (s *Server) require(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := requestUser(r)
+		if !user.HasRole(role) {
+			http.Error(w, fmt.Sprintf("Forbidden: requires the %s role", role), http.StatusForbidden)
+			return
+		}
+		if name := r.PathValue("name"); name != "" && !s.canAccessService(user, name) {
+			http.Error(w, fmt.Sprintf("Forbidden: no access to service %s", name), http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
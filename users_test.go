@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoadUsers(t *testing.T) {
+	if users := loadUsers(GlobalConfig{}); users != nil {
+		t.Errorf("Expected authentication to be disabled, got %v", users)
+	}
+
+	users := loadUsers(GlobalConfig{
+		Authorization: "root:secret",
+		Users: []UserConfig{
+			{Name: "alice", Password: "a", Role: RoleOperator},
+			{Name: "bob", Password: "b"},
+			{Name: "carol", Password: "c", Role: "superuser"},
+			{Name: "dave"},
+			{Name: "root", Password: "other", Role: RoleViewer},
+		},
+	})
+	if len(users) != 4 || users["root"].Role != RoleAdmin || users["root"].password != "secret" {
+		t.Fatalf("Unexpected users %+v", users)
+	}
+	if users["alice"].Role != RoleOperator || users["bob"].Role != RoleViewer || users["carol"].Role != RoleViewer {
+		t.Errorf("Unexpected roles: alice %s, bob %s, carol %s", users["alice"].Role, users["bob"].Role, users["carol"].Role)
+	}
+
+	// Password-only authorization has an empty username
+	if users := loadUsers(GlobalConfig{Authorization: "secret"}); users[""] == nil || users[""].password != "secret" {
+		t.Errorf("Expected a password-only admin, got %v", users)
+	}
+}
+
+func TestUser_CanAccess(t *testing.T) {
+	user := &User{Role: RoleViewer, services: []string{"web-*"}, labels: []string{"team-a"}}
+
+	tests := []struct {
+		cfg    ServiceConfig
+		access bool
+	}{
+		{ServiceConfig{Name: "web-api"}, true},
+		{ServiceConfig{Name: "worker", Labels: []string{"team-b", "team-a"}}, true},
+		{ServiceConfig{Name: "worker", Labels: []string{"team-b"}}, false},
+		{ServiceConfig{Name: "web"}, false},
+	}
+	for _, tt := range tests {
+		if got := user.CanAccess(tt.cfg); got != tt.access {
+			t.Errorf("CanAccess(%s %v) = %v, expected %v", tt.cfg.Name, tt.cfg.Labels, got, tt.access)
+		}
+	}
+
+	if !(&User{Role: RoleViewer}).CanAccess(ServiceConfig{Name: "anything"}) {
+		t.Error("Expected a user without grants to access all services")
+	}
+}
+
+func TestServer_RolesAndGrants(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{Users: []UserConfig{
+		{Name: "admin", Password: "a", Role: RoleAdmin},
+		{Name: "ops", Password: "o", Role: RoleOperator, Services: []string{"api"}},
+		{Name: "contractor", Password: "c", Labels: []string{"external"}},
+	}})
+	defer m.StopAll()
+	m.OnServicesUpdated([]ServiceConfig{
+		{Name: "api", Command: "true", Type: ServiceTypeManual, Env: map[string]string{"TOKEN": "x"}},
+		{Name: "billing", Command: "true", Type: ServiceTypeManual, Labels: []string{"external"}},
+	}, nil)
+	handler := NewServer(m, nil).handler()
+
+	request := func(method, path, username, password string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("GET", "/api/services", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %d", rec.Code)
+	}
+	if rec := request("GET", "/api/services", "admin", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong password, got %d", rec.Code)
+	}
+
+	// Services are listed only if granted
+	listed := func(username, password string) []string {
+		t.Helper()
+		rec := request("GET", "/api/services", username, password)
+		var services []map[string]any
+		json.NewDecoder(rec.Body).Decode(&services)
+		var names []string
+		for _, svc := range services {
+			names = append(names, svc["name"].(string))
+		}
+		return names
+	}
+	if names := listed("admin", "a"); len(names) != 2 {
+		t.Errorf("Expected admin to see all services, got %v", names)
+	}
+	if names := listed("contractor", "c"); len(names) != 1 || names[0] != "billing" {
+		t.Errorf("Expected contractor to see billing only, got %v", names)
+	}
+
+	tests := []struct {
+		method, path, user, password string
+		forbidden                    bool
+	}{
+		{"GET", "/api/services/billing/logs/stdout/range", "contractor", "c", false},
+		{"GET", "/api/services/api/logs/stdout/range", "contractor", "c", true},
+		{"POST", "/api/services/billing/stop", "contractor", "c", true},
+		{"POST", "/api/services/api/stop", "ops", "o", false},
+		{"POST", "/api/services/billing/stop", "ops", "o", true},
+		{"DELETE", "/api/services/api", "ops", "o", true},
+		{"GET", "/api/audit", "ops", "o", true},
+		{"GET", "/api/audit", "admin", "a", false},
+	}
+	for _, tt := range tests {
+		rec := request(tt.method, tt.path, tt.user, tt.password)
+		if forbidden := rec.Code == http.StatusForbidden; forbidden != tt.forbidden {
+			t.Errorf("%s %s as %s: got %d, expected forbidden %v", tt.method, tt.path, tt.user, rec.Code, tt.forbidden)
+		}
+	}
+
+	// Operators can run the configured command, but not with arguments or environment overrides
+	for _, body := range []string{`{"env": {"LD_PRELOAD": "/tmp/x.so"}}`, `{"args_raw": "-c id"}`} {
+		req := httptest.NewRequest("POST", "/api/services/api/run-now", strings.NewReader(body))
+		req.SetBasicAuth("ops", "o")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for run-now with %s as operator, got %d", body, rec.Code)
+		}
+	}
+	if rec := request("POST", "/api/services/api/run-now", "ops", "o"); rec.Code != http.StatusOK {
+		t.Errorf("Expected a plain run-now as operator to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The environment is only shown to admins
+	var detail map[string]any
+	json.NewDecoder(request("GET", "/api/services/api", "ops", "o").Body).Decode(&detail)
+	if _, ok := detail["env"]; ok {
+		t.Errorf("Expected no env for an operator, got %v", detail["env"])
+	}
+
+	// The cookie set on login authenticates as the same user
+	rec := request("GET", "/api/me", "ops", "o")
+	req := httptest.NewRequest("GET", "/api/me", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var me map[string]string
+	json.NewDecoder(rec.Body).Decode(&me)
+	if me["name"] != "ops" || me["role"] != RoleOperator {
+		t.Errorf("Expected ops via the cookie, got %d %v", rec.Code, me)
+	}
+
+	// A cookie of another user's name doesn't work without the password
+	req = httptest.NewRequest("GET", "/api/me", nil)
+	req.AddCookie(&http.Cookie{Name: authCookieName, Value: base64.StdEncoding.EncodeToString([]byte("admin:o"))})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a forged cookie, got %d", rec.Code)
+	}
+}
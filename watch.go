@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	watchPollInterval    = 500 * time.Millisecond // How often watched paths are scanned for changes
+	watchChangedFilesEnv = "SM_CHANGED_FILES"     // Environment variable listing the changed files of a watch run
+)
+
+// fileState is the part of a file's metadata used to detect modifications
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// FileWatcher polls a set of paths and glob patterns and reports created or modified files.
+// Changes are collected until no new change has been seen for the debounce duration,
+// then reported together in a single callback.
+type FileWatcher struct {
+	patterns []string // Absolute paths or glob patterns (directories match the files directly inside them)
+	debounce time.Duration
+	interval time.Duration
+	onChange func(changed []string)
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFileWatcher creates a watcher for the given patterns (relative patterns are resolved against baseDir)
+func NewFileWatcher(patterns []string, baseDir string, debounce time.Duration, onChange func(changed []string)) *FileWatcher {
+	resolved := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) && baseDir != "" {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+		resolved = append(resolved, filepath.Clean(pattern))
+	}
+
+	return &FileWatcher{
+		patterns: resolved,
+		debounce: debounce,
+		interval: watchPollInterval,
+		onChange: onChange,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins watching in the background. Files that already exist do not trigger a change.
+func (w *FileWatcher) Start() {
+	initial := w.scan()
+	go w.run(initial)
+}
+
+// Stop stops watching and waits for the background goroutine to exit.
+// Pending (debounced) changes are discarded.
+func (w *FileWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+	})
+	<-w.done
+}
+
+// run polls the watched paths until stopped
+func (w *FileWatcher) run(known map[string]fileState) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	changed := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		current := w.scan()
+		for path, state := range current {
+			if prev, exists := known[path]; !exists || !prev.modTime.Equal(state.modTime) || prev.size != state.size {
+				changed[path] = true
+				lastChange = time.Now()
+			}
+		}
+		known = current
+
+		// Report once the files have been quiet for the debounce duration
+		if len(changed) > 0 && time.Since(lastChange) >= w.debounce {
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			changed = make(map[string]bool)
+
+			w.onChange(paths)
+		}
+	}
+}
+
+// scan returns the current state of all files matched by the watched patterns
+func (w *FileWatcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+
+	add := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	for _, pattern := range w.patterns {
+		// A plain directory watches the files directly inside it
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			entries, err := os.ReadDir(pattern)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					add(filepath.Join(pattern, entry.Name()))
+				}
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			add(match)
+		}
+	}
+
+	return files
+}
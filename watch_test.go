@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectChanges returns a watcher callback that records reported changes
+func collectChanges() (func([]string), func() [][]string) {
+	var mu sync.Mutex
+	var batches [][]string
+	record := func(changed []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, changed)
+	}
+	get := func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string{}, batches...)
+	}
+	return record, get
+}
+
+func waitForBatches(get func() [][]string, n int, timeout time.Duration) [][]string {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if batches := get(); len(batches) >= n {
+			return batches
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return get()
+}
+
+func TestFileWatcher_DirectoryReportsNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.csv"), []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	record, get := collectChanges()
+	w := NewFileWatcher([]string{filepath.Base(dir)}, filepath.Dir(dir), 50*time.Millisecond, record)
+	w.interval = 10 * time.Millisecond
+	w.Start()
+	defer w.Stop()
+
+	newFile := filepath.Join(dir, "new.csv")
+	if err := os.WriteFile(newFile, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	batches := waitForBatches(get, 1, 2*time.Second)
+	if len(batches) != 1 {
+		t.Fatalf("Expected 1 change batch, got %d", len(batches))
+	}
+	if len(batches[0]) != 1 || batches[0][0] != newFile {
+		t.Errorf("Expected only %s to be reported, got %v", newFile, batches[0])
+	}
+}
+
+func TestFileWatcher_GlobAndDebounce(t *testing.T) {
+	dir := t.TempDir()
+
+	record, get := collectChanges()
+	w := NewFileWatcher([]string{"*.txt"}, dir, 200*time.Millisecond, record)
+	w.interval = 10 * time.Millisecond
+	w.Start()
+	defer w.Stop()
+
+	// Several quick writes should be reported as a single batch
+	for _, name := range []string{"a.txt", "b.txt", "ignored.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	waitForBatches(get, 1, 2*time.Second)
+	time.Sleep(300 * time.Millisecond)
+	batches := get()
+	if len(batches) != 1 {
+		t.Fatalf("Expected 1 debounced batch, got %d: %v", len(batches), batches)
+	}
+	want := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	if !slices.Equal(batches[0], want) {
+		t.Errorf("Expected %v, got %v", want, batches[0])
+	}
+
+	// Modifying a file triggers again
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	batches = waitForBatches(get, 2, 2*time.Second)
+	if len(batches) != 2 {
+		t.Fatalf("Expected modification to be reported, got %v", batches)
+	}
+}
+
+func TestWatchConfig_DebounceDuration(t *testing.T) {
+	tests := []struct {
+		debounce string
+		want     time.Duration
+	}{
+		{"", defaultWatchDebounce},
+		{"5s", 5 * time.Second},
+		{"invalid", defaultWatchDebounce},
+	}
+
+	for _, tt := range tests {
+		wc := WatchConfig{Paths: []string{"inbox"}, Debounce: tt.debounce}
+		if got := wc.DebounceDuration(); got != tt.want {
+			t.Errorf("DebounceDuration(%q) = %v, want %v", tt.debounce, got, tt.want)
+		}
+	}
+}
+
+func TestServiceManager_WatchRearmedAfterEdit(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+
+	watched := func(name string) bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		_, ok := m.watchers[name]
+		return ok
+	}
+
+	job := ServiceConfig{Name: "job", Command: "true", Type: ServiceTypeManual, Watch: &WatchConfig{Paths: []string{"inbox"}}}
+	m.OnServicesUpdated([]ServiceConfig{job}, nil)
+	if !watched("job") {
+		t.Fatal("Expected the new manual service to be watched")
+	}
+
+	// Editing the idle service keeps it watched
+	job.Command = "echo edited"
+	m.OnServicesUpdated([]ServiceConfig{job}, []string{"job"})
+	if !watched("job") {
+		t.Error("Expected the edited service to be watched again")
+	}
+
+	disabled := false
+	job.Enabled = &disabled
+	m.OnServicesUpdated([]ServiceConfig{job}, []string{"job"})
+	if watched("job") {
+		t.Error("Expected the disabled service not to be watched")
+	}
+}
@@ -17,6 +17,18 @@ type FailurePayload struct {
 	ErrorMessage string    `json:"error_message,omitempty"`
 }
 
+// AlertPayload represents the webhook payload for a log line matching an alert pattern
+type AlertPayload struct {
+	ServiceName string    `json:"service_name"`
+	Timestamp   time.Time `json:"timestamp"`
+	Event       string    `json:"event"` // Always "log_alert", to tell alerts from failures
+	Pattern     string    `json:"pattern"`
+	Stream      string    `json:"stream"`
+	Line        string    `json:"line"`
+	Before      []string  `json:"before"` // Lines of the same stream before the match
+	After       []string  `json:"after"`  // Lines of the same stream after the match
+}
+
 // Notifier handles webhook notifications
 type Notifier struct {
 	webhookURL string
@@ -37,6 +49,16 @@ func NewNotifier(webhookURL string) *Notifier {
 
 // NotifyFailure sends a failure notification to the webhook
 func (n *Notifier) NotifyFailure(payload FailurePayload) error {
+	return n.post(payload)
+}
+
+// NotifyAlert sends a log alert notification to the webhook
+func (n *Notifier) NotifyAlert(payload AlertPayload) error {
+	return n.post(payload)
+}
+
+// post sends a payload to the webhook as JSON
+func (n *Notifier) post(payload any) error {
 	if !n.enabled {
 		return nil // Webhook disabled
 	}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// muxQueueSize is the number of frames a multiplexed connection queues for writing. When the
+// client reads too slowly the queue fills up, the subscriptions stop reading their streams and
+// the streams drop lines for them (reported with a dropped-lines marker).
+const muxQueueSize = 256
+
+// MuxRequest is a control message of the multiplexed WebSocket
+type MuxRequest struct {
+	Action  string `json:"action"`  // subscribe or unsubscribe
+	Service string `json:"service"` // Empty for the event feed
+	Stream  string `json:"stream"`  // stdout, stderr, combined, or events for the event feed
+	After   *int64 `json:"after"`   // Resume after this seq instead of sending the buffer
+	Tail    *int   `json:"tail"`    // Replay the last N lines from the log files
+	Level   string `json:"level"`   // Minimum log level
+}
+
+// MuxFrame is a message of the multiplexed WebSocket, tagged with its subscription
+type MuxFrame struct {
+	Type    string        `json:"type"` // log, event, subscribed, unsubscribed or error
+	Service string        `json:"service,omitempty"`
+	Stream  string        `json:"stream,omitempty"`
+	Line    *LogLine      `json:"line,omitempty"`
+	Event   *EventMessage `json:"event,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// muxKey identifies a subscription of a multiplexed connection
+type muxKey struct {
+	service, stream string
+}
+
+// muxSubscription is a running subscription of a multiplexed connection
+type muxSubscription struct {
+	cancel context.CancelFunc
+	done   chan struct{} // Closed once the subscription sends no more frames
+}
+
+// muxSession is a multiplexed WebSocket connection with its subscriptions
+type muxSession struct {
+	server *Server
+	user   *User // Authenticated user, limits the services that can be subscribed to
+	conn   *websocket.Conn
+	ctx    context.Context
+	out    chan MuxFrame
+	subs   map[muxKey]muxSubscription // Only used by the reading goroutine
+	wg     sync.WaitGroup             // Running subscriptions
+}
+
+// streamMux serves a single WebSocket that carries any number of log streams and the event feed.
+// The client subscribes and unsubscribes with MuxRequest messages and receives MuxFrames.
+func (s *Server) streamMux(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &muxSession{
+		server: s,
+		user:   requestUser(r),
+		conn:   conn,
+		ctx:    ctx,
+		out:    make(chan MuxFrame, muxQueueSize),
+		subs:   make(map[muxKey]muxSubscription),
+	}
+
+	// All frames are written by one goroutine
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for frame := range session.out {
+			if err := conn.WriteJSON(frame); err != nil {
+				cancel()
+				conn.Close() // Unblocks the reader
+				break
+			}
+		}
+		// Keep draining until the subscriptions are stopped
+		for range session.out {
+		}
+	}()
+
+	for {
+		var req MuxRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			break
+		}
+		session.handle(req)
+	}
+
+	cancel()
+	session.wg.Wait()
+	close(session.out)
+	<-writerDone
+}
+
+// handle applies a control message
+func (ms *muxSession) handle(req MuxRequest) {
+	key := muxKey{req.Service, req.Stream}
+	switch req.Action {
+	case "subscribe":
+		ms.unsubscribe(key)
+		forward, err := ms.subscription(req)
+		if err != nil {
+			ms.send(MuxFrame{Type: "error", Service: req.Service, Stream: req.Stream, Error: err.Error()})
+			return
+		}
+		ms.send(MuxFrame{Type: "subscribed", Service: req.Service, Stream: req.Stream})
+		ms.start(key, forward)
+	case "unsubscribe":
+		ms.unsubscribe(key)
+		ms.send(MuxFrame{Type: "unsubscribed", Service: req.Service, Stream: req.Stream})
+	default:
+		ms.send(MuxFrame{Type: "error", Service: req.Service, Stream: req.Stream, Error: fmt.Sprintf("unknown action %q", req.Action)})
+	}
+}
+
+// send queues a frame, giving up when the connection is closing
+func (ms *muxSession) send(frame MuxFrame) bool {
+	return ms.sendFrom(ms.ctx, frame)
+}
+
+// unsubscribe stops a subscription if it exists and waits until it sent its last frame, so
+// none of its frames follow the unsubscribed (or a new subscribed) frame
+func (ms *muxSession) unsubscribe(key muxKey) {
+	if sub, ok := ms.subs[key]; ok {
+		sub.cancel()
+		<-sub.done
+		delete(ms.subs, key)
+	}
+}
+
+// subscription validates a subscribe request. Returns the function that forwards the
+// requested log stream or the event feed.
+func (ms *muxSession) subscription(req MuxRequest) (func(ctx context.Context), error) {
+	if req.Stream == "events" {
+		if req.Service != "" {
+			return nil, fmt.Errorf("the event feed has no service")
+		}
+		return ms.forwardEvents, nil
+	}
+
+	if req.Stream != "stdout" && req.Stream != "stderr" && req.Stream != "combined" {
+		return nil, fmt.Errorf("stream must be stdout, stderr, combined or events")
+	}
+	svc, err := ms.server.serviceManager.GetService(req.Service)
+	if err != nil {
+		return nil, err
+	}
+	if !ms.user.CanAccess(svc.Config) {
+		return nil, fmt.Errorf("no access to service %s", req.Service)
+	}
+	minLevel, err := parseLogLevelParam(req.Level)
+	if err != nil {
+		return nil, err
+	}
+	after, tail := int64(-1), -1
+	if req.After != nil {
+		after = *req.After
+	}
+	if req.Tail != nil {
+		tail = min(max(*req.Tail, 0), maxRangeLines)
+	}
+
+	return func(ctx context.Context) {
+		ms.forwardLogs(ctx, svc, req.Stream, after, tail, minLevel)
+	}, nil
+}
+
+// start runs a subscription until it is cancelled or the connection closes
+func (ms *muxSession) start(key muxKey, forward func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(ms.ctx)
+	sub := muxSubscription{cancel: cancel, done: make(chan struct{})}
+	ms.subs[key] = sub
+	ms.wg.Add(1)
+	go func() {
+		defer ms.wg.Done()
+		defer close(sub.done)
+		forward(ctx)
+	}()
+}
+
+// forwardLogs sends the history and then the live lines of a log stream
+func (ms *muxSession) forwardLogs(ctx context.Context, svc *Service, stream string, after int64, tail int, minLevel int) {
+	// Subscribe before reading the history, so no line is missed in between
+	ch, unsubscribe := subscribeLogStream(svc, stream)
+	defer unsubscribe()
+
+	frame := func(line LogLine) MuxFrame {
+		return MuxFrame{Type: "log", Service: svc.Config.Name, Stream: stream, Line: &line}
+	}
+
+	lastSeq := after
+	for _, line := range logStreamHistory(svc, stream, after, tail) {
+		lastSeq = max(lastSeq, line.Seq)
+		if line.Dropped > 0 || line.hasMinLevel(minLevel) {
+			if !ms.sendFrom(ctx, frame(line)) {
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-ch:
+			if !ok {
+				return
+			}
+			if line.Dropped == 0 && (line.Seq <= lastSeq || !line.hasMinLevel(minLevel)) {
+				continue
+			}
+			if !ms.sendFrom(ctx, frame(line)) {
+				return
+			}
+		}
+	}
+}
+
+// forwardEvents sends the events of the event bus
+func (ms *muxSession) forwardEvents(ctx context.Context) {
+	bus := ms.server.serviceManager.Events()
+	events := bus.Subscribe()
+	defer bus.Unsubscribe(events)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Service != "" && !ms.server.canAccessService(ms.user, event.Service) {
+				continue
+			}
+			msg := ms.server.eventMessage(event)
+			if !ms.sendFrom(ctx, MuxFrame{Type: "event", Stream: "events", Event: &msg}) {
+				return
+			}
+		}
+	}
+}
+
+// sendFrom queues a frame of a subscription, giving up when the subscription is cancelled.
+// Blocks while the write queue is full, which makes the subscribed stream drop lines instead.
+func (ms *muxSession) sendFrom(ctx context.Context, frame MuxFrame) bool {
+	select {
+	case ms.out <- frame:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,142 @@
+//go:build !windows
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestStreamMux_SubscribeLogsAndEvents(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+	m.OnServicesUpdated([]ServiceConfig{{Name: "api", Command: `sh -c "echo one; echo two; sleep 10"`}}, nil)
+
+	s := NewServer(m, nil)
+	server := httptest.NewServer(http.HandlerFunc(s.streamMux))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	read := func() MuxFrame {
+		t.Helper()
+		var frame MuxFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		return frame
+	}
+
+	conn.WriteJSON(MuxRequest{Action: "subscribe", Service: "missing", Stream: "stdout"})
+	if frame := read(); frame.Type != "error" || frame.Service != "missing" {
+		t.Errorf("expected error frame, got %+v", frame)
+	}
+
+	conn.WriteJSON(MuxRequest{Action: "subscribe", Stream: "events"})
+	if frame := read(); frame.Type != "subscribed" || frame.Stream != "events" {
+		t.Errorf("expected subscribed frame, got %+v", frame)
+	}
+
+	// Wait for the output, then subscribe: the lines come from the buffer
+	time.Sleep(500 * time.Millisecond)
+	conn.WriteJSON(MuxRequest{Action: "subscribe", Service: "api", Stream: "stdout"})
+	if frame := read(); frame.Type != "subscribed" || frame.Service != "api" {
+		t.Errorf("expected subscribed frame, got %+v", frame)
+	}
+	var texts []string
+	for len(texts) < 2 {
+		frame := read()
+		if frame.Type != "log" || frame.Service != "api" || frame.Stream != "stdout" || frame.Line.Seq == 0 {
+			t.Fatalf("expected tagged log frame, got %+v", frame)
+		}
+		if !strings.HasPrefix(frame.Line.Text, "[service-manager]") {
+			texts = append(texts, frame.Line.Text)
+		}
+	}
+	if strings.Join(texts, ",") != "one,two" {
+		t.Errorf("unexpected lines %v", texts)
+	}
+
+	// Events arrive on the same connection
+	m.StopService("api")
+	for {
+		frame := read()
+		if frame.Type == "event" && frame.Event.Type == EventExited {
+			if frame.Event.Service != "api" || frame.Event.Status["name"] != "api" {
+				t.Errorf("unexpected event %+v", frame.Event)
+			}
+			break
+		}
+	}
+}
+
+func TestStreamMux_NoFramesAfterResubscribe(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m := NewServiceManager(GlobalConfig{})
+	defer m.StopAll()
+	m.OnServicesUpdated([]ServiceConfig{{Name: "api", Command: `sh -c "while true; do echo line; done"`}}, nil)
+
+	s := NewServer(m, nil)
+	server := httptest.NewServer(http.HandlerFunc(s.streamMux))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Reads frames until the next one of the given type; fails on timeout
+	readUntil := func(frameType string) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		for {
+			var frame MuxFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				t.Fatalf("read frame: %v", err)
+			}
+			if frame.Type == frameType {
+				return
+			}
+		}
+	}
+	// Expects no log lines before the reply to an unsubscribe of the (unsubscribed) event feed
+	expectNoLines := func(after string) {
+		t.Helper()
+		conn.WriteJSON(MuxRequest{Action: "unsubscribe", Stream: "events"})
+		for {
+			var frame MuxFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				t.Fatalf("read frame: %v", err)
+			}
+			if frame.Type == "unsubscribed" {
+				return
+			}
+			if frame.Type == "log" && frame.Line.Dropped == 0 {
+				t.Fatalf("expected no log lines after %s, got %+v", after, frame.Line)
+			}
+		}
+	}
+
+	future := int64(1) << 62 // Every line is before this, so the new subscription forwards none
+	for range 20 {
+		conn.WriteJSON(MuxRequest{Action: "subscribe", Service: "api", Stream: "stdout"})
+		readUntil("subscribed")
+		conn.WriteJSON(MuxRequest{Action: "subscribe", Service: "api", Stream: "stdout", After: &future})
+		readUntil("subscribed")
+		expectNoLines("re-subscribing")
+	}
+}